@@ -0,0 +1,528 @@
+package browser
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Credentials authenticate requests to a host. A Token is sent as a Bearer
+// token; otherwise Username and Password are used for Basic or Digest auth,
+// whichever the server asks for. Credentials are only sent over https and to
+// loopback hosts unless Insecure is set.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+	Insecure bool // Also send them over plain http
+}
+
+// AuthChallenge is a scheme and its parameters from a WWW-Authenticate header
+type AuthChallenge struct {
+	Scheme string // Lower case, such as "basic", "digest" or "bearer"
+	Params map[string]string
+}
+
+// Realm returns the protection space named by the challenge
+func (c AuthChallenge) Realm() string {
+	return c.Params["realm"]
+}
+
+// hostAuth remembers how an origin authenticated so later requests can send credentials right away
+type hostAuth struct {
+	scheme string
+	digest *AuthChallenge
+	count  int
+}
+
+// CredentialStore holds credentials per host. Hosts are matched like
+// no_proxy entries: ".example.com" also matches subdomains.
+type CredentialStore struct {
+	mu          sync.Mutex
+	hosts       []string
+	credentials map[string]Credentials
+	state       map[string]*hostAuth // By origin, so other schemes and ports of a host start over
+}
+
+// NewCredentialStore creates an empty credential store
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{
+		credentials: make(map[string]Credentials),
+		state:       make(map[string]*hostAuth),
+	}
+}
+
+// Set stores credentials for host, replacing earlier ones
+func (s *CredentialStore) Set(host string, credentials Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host = strings.ToLower(host)
+	if _, exists := s.credentials[host]; !exists {
+		s.hosts = append(s.hosts, host)
+	}
+	s.credentials[host] = credentials
+	for origin := range s.state {
+		if u, err := url.Parse(origin); err == nil && hostMatches(u.Hostname(), []string{host}) {
+			delete(s.state, origin)
+		}
+	}
+}
+
+// Lookup returns the credentials for host; an exact entry wins over a domain entry
+func (s *CredentialStore) Lookup(host string) (Credentials, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.match(host)
+	if !ok {
+		return Credentials{}, false
+	}
+	return s.credentials[key], true
+}
+
+// match returns the store key that matches host
+func (s *CredentialStore) match(host string) (string, bool) {
+	host = strings.ToLower(host)
+	if _, ok := s.credentials[host]; ok {
+		return host, true
+	}
+	for _, pattern := range s.hosts {
+		if hostMatches(host, []string{pattern}) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Each calls fn for every host and its credentials, in the order they were added
+func (s *CredentialStore) Each(fn func(host string, credentials Credentials)) {
+	s.mu.Lock()
+	hosts := append([]string(nil), s.hosts...)
+	s.mu.Unlock()
+	for _, host := range hosts {
+		if credentials, ok := s.Lookup(host); ok {
+			fn(host, credentials)
+		}
+	}
+}
+
+// LoadEnv adds credentials from an environment variable value such as
+// "wiki.corp.example=alice:secret,api.example.com=bearer:TOKEN"
+func (s *CredentialStore) LoadEnv(value string) error {
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		host, secret, found := strings.Cut(entry, "=")
+		user, password, hasColon := strings.Cut(secret, ":")
+		if !found || host == "" || !hasColon {
+			return fmt.Errorf("invalid credentials entry %q (use host=user:password or host=bearer:token)", entry)
+		}
+		if strings.EqualFold(user, "bearer") {
+			s.Set(host, Credentials{Token: password})
+		} else {
+			s.Set(host, Credentials{Username: user, Password: password})
+		}
+	}
+	return nil
+}
+
+// LoadNetrc adds the machine entries of a .netrc file. A missing file is not an
+// error. The "default" entry is ignored so credentials never reach unknown hosts.
+func (s *CredentialStore) LoadNetrc(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read netrc: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := parseNetrc(file)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, exists := s.Lookup(entry.machine); !exists {
+			s.Set(entry.machine, Credentials{Username: entry.login, Password: entry.password})
+		}
+	}
+	return nil
+}
+
+// DefaultNetrcPath returns $NETRC or ~/.netrc
+func DefaultNetrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %v", err)
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// netrcEntry is a machine entry of a .netrc file
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// parseNetrc reads the machine, login and password tokens of a .netrc file
+func parseNetrc(r io.Reader) ([]netrcEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	var entries []netrcEntry
+	var current *netrcEntry
+	inMacro := false
+	for scanner.Scan() {
+		token := scanner.Text()
+		if inMacro {
+			// Macro definitions run until an empty line, which ScanWords cannot see;
+			// a following machine keyword ends them as well
+			if token != "machine" && token != "default" {
+				continue
+			}
+			inMacro = false
+		}
+		switch token {
+		case "machine":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("netrc: machine without a name")
+			}
+			entries = append(entries, netrcEntry{machine: scanner.Text()})
+			current = &entries[len(entries)-1]
+		case "default":
+			current = nil
+		case "login", "password", "account":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("netrc: %s without a value", token)
+			}
+			if current == nil {
+				continue
+			}
+			if token == "login" {
+				current.login = scanner.Text()
+			} else if token == "password" {
+				current.password = scanner.Text()
+			}
+		case "macdef":
+			scanner.Scan()
+			inMacro = true
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ParseAuthChallenges parses the WWW-Authenticate headers of a response
+func ParseAuthChallenges(header http.Header) []AuthChallenge {
+	var challenges []AuthChallenge
+	for _, value := range header.Values("WWW-Authenticate") {
+		rest := value
+		for {
+			rest = strings.TrimLeft(rest, " \t,")
+			if rest == "" {
+				break
+			}
+			// A scheme is a token not followed by "="
+			end := strings.IndexAny(rest, " \t,=")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				rest = rest[1:] // Stray "=" without a name
+				continue
+			}
+			if end < len(rest) && rest[end] == '=' && len(challenges) > 0 {
+				var key, val string
+				key, val, rest = parseAuthParam(rest)
+				challenges[len(challenges)-1].Params[key] = val
+				continue
+			}
+			challenges = append(challenges, AuthChallenge{Scheme: strings.ToLower(rest[:end]), Params: make(map[string]string)})
+			rest = rest[end:]
+
+			// token68 form such as "Negotiate abc=="
+			trimmed := strings.TrimLeft(rest, " \t")
+			if token := strings.IndexAny(trimmed, " \t,="); token > 0 && trimmed[token] == '=' && strings.Trim(trimmed[token:], "=") == "" {
+				rest = ""
+			}
+		}
+	}
+	return challenges
+}
+
+// parseAuthParam reads key=value or key="quoted value" and returns the remaining input
+func parseAuthParam(input string) (key, value, rest string) {
+	eq := strings.Index(input, "=")
+	key = strings.ToLower(strings.TrimSpace(input[:eq]))
+	rest = strings.TrimLeft(input[eq+1:], " \t")
+	if strings.HasPrefix(rest, `"`) {
+		var b strings.Builder
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+			}
+			b.WriteByte(rest[i])
+		}
+		return key, b.String(), rest[min(i+1, len(rest)):]
+	}
+	end := strings.IndexAny(rest, ",")
+	if end < 0 {
+		end = len(rest)
+	}
+	return key, strings.TrimSpace(rest[:end]), rest[end:]
+}
+
+// PreferredChallenge returns the strongest challenge brauser can answer:
+// Digest over Basic over Bearer. ok is false if none is supported.
+func PreferredChallenge(challenges []AuthChallenge) (AuthChallenge, bool) {
+	for _, scheme := range []string{"digest", "basic", "bearer"} {
+		for _, challenge := range challenges {
+			if challenge.Scheme != scheme {
+				continue
+			}
+			if scheme == "digest" && digestHash(challenge.Params["algorithm"]) == nil {
+				continue
+			}
+			return challenge, true
+		}
+	}
+	return AuthChallenge{}, false
+}
+
+// digestHash returns the hash function of a Digest algorithm, or nil if it is unsupported
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// digestAuthorization computes the Authorization header answering a Digest challenge
+func digestAuthorization(challenge *AuthChallenge, credentials Credentials, method, uri string, count int) string {
+	params := challenge.Params
+	newHash := digestHash(params["algorithm"])
+	h := func(value string) string {
+		sum := newHash()
+		sum.Write([]byte(value))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := fmt.Sprintf("%08x", count)
+
+	ha1 := h(credentials.Username + ":" + params["realm"] + ":" + credentials.Password)
+	if strings.HasSuffix(strings.ToLower(params["algorithm"]), "-sess") {
+		ha1 = h(ha1 + ":" + params["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, params["nonce"], nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + params["nonce"] + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, credentials.Username),
+		fmt.Sprintf(`realm="%s"`, params["realm"]),
+		fmt.Sprintf(`nonce="%s"`, params["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if algorithm := params["algorithm"]; algorithm != "" {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+// authTransport adds credentials to requests for hosts in the store. Tokens
+// and known Basic hosts are sent right away; otherwise the request is retried
+// once after a 401 with the scheme the server asked for.
+type authTransport struct {
+	store *CredentialStore
+	next  http.RoundTripper
+}
+
+// RoundTrip authenticates the request if credentials for its host are known
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.next.RoundTrip(req)
+	}
+	host := req.URL.Hostname()
+	credentials, ok := t.store.Lookup(host)
+	if !ok || !credentials.Insecure && !secureOrigin(req.URL) {
+		return t.next.RoundTrip(req)
+	}
+	if credentials.Token != "" {
+		return t.next.RoundTrip(withAuthorization(req, "Bearer "+credentials.Token))
+	}
+
+	if authorization := t.store.preemptive(credentials, req); authorization != "" {
+		resp, err := t.next.RoundTrip(withAuthorization(req, authorization))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		// A stale nonce or changed password: answer the new challenge below
+		return t.retry(req, resp, credentials)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	return t.retry(req, resp, credentials)
+}
+
+// retry answers the challenge of a 401 response, returning it unchanged if that is not possible
+func (t *authTransport) retry(req *http.Request, resp *http.Response, credentials Credentials) (*http.Response, error) {
+	challenge, ok := PreferredChallenge(ParseAuthChallenges(resp.Header))
+	if !ok || challenge.Scheme == "bearer" {
+		return resp, nil
+	}
+	retry, err := rewindRequest(req)
+	if err != nil {
+		return resp, nil
+	}
+
+	authorization := t.store.answer(challenge, credentials, req)
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return t.next.RoundTrip(withAuthorization(retry, authorization))
+}
+
+// preemptive returns an Authorization header for an origin whose scheme is already known
+func (s *CredentialStore) preemptive(credentials Credentials, req *http.Request) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.state[requestOrigin(req.URL)]
+	if state == nil {
+		return ""
+	}
+	switch state.scheme {
+	case "basic":
+		return basicAuthorization(credentials)
+	case "digest":
+		state.count++
+		return digestAuthorization(state.digest, credentials, req.Method, req.URL.RequestURI(), state.count)
+	}
+	return ""
+}
+
+// answer remembers the challenged scheme for the origin and returns the matching Authorization header
+func (s *CredentialStore) answer(challenge AuthChallenge, credentials Credentials, req *http.Request) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := &hostAuth{scheme: challenge.Scheme}
+	s.state[requestOrigin(req.URL)] = state
+	if challenge.Scheme == "basic" {
+		return basicAuthorization(credentials)
+	}
+	state.digest = &challenge
+	state.count = 1
+	return digestAuthorization(state.digest, credentials, req.Method, req.URL.RequestURI(), state.count)
+}
+
+// requestOrigin returns the scheme, host and port of a URL
+func requestOrigin(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if strings.EqualFold(u.Scheme, "https") {
+			port = "443"
+		}
+	}
+	return strings.ToLower(u.Scheme) + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// secureOrigin reports whether credentials may be sent to a URL without
+// exposing them on the network: over https or to a loopback host
+func secureOrigin(u *url.URL) bool {
+	if strings.EqualFold(u.Scheme, "https") {
+		return true
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Unmap().IsLoopback()
+}
+
+// basicAuthorization returns the Authorization header for Basic auth
+func basicAuthorization(credentials Credentials) string {
+	req := &http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+	return req.Header.Get("Authorization")
+}
+
+// withAuthorization returns a copy of req with the Authorization header set
+func withAuthorization(req *http.Request, authorization string) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", authorization)
+	return clone
+}
+
+// rewindRequest returns a copy of req that can be sent again, with a fresh body
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be sent again")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
+
+// SetCredentials adds credentials for host, such as "wiki.corp.example" or
+// ".corp.example" for all its subdomains, to every request of this client
+func (c *Client) SetCredentials(host string, credentials Credentials) {
+	if c.credentials == nil {
+		c.credentials = NewCredentialStore()
+		c.rebuildTransport()
+	}
+	c.credentials.Set(host, credentials)
+}
+
+// SetCredentialStore replaces the credentials used by this client; nil turns authentication off
+func (c *Client) SetCredentialStore(store *CredentialStore) {
+	c.credentials = store
+	c.rebuildTransport()
+}
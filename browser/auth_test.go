@@ -0,0 +1,119 @@
+package browser
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestAuthSchemes checks that Basic, Digest and Bearer credentials are sent
+// only to their host and that a 401 without credentials stays an error
+func TestAuthSchemes(t *testing.T) {
+	md5hex := func(value string) string {
+		sum := md5.Sum([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	const nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized := false
+		switch r.URL.Path {
+		case "/basic":
+			user, password, ok := r.BasicAuth()
+			authorized = ok && user == "alice" && password == "secret"
+			w.Header().Set("WWW-Authenticate", `Basic realm="wiki"`)
+		case "/digest":
+			params := ParseAuthChallenges(http.Header{"Www-Authenticate": {r.Header.Get("Authorization")}})
+			if len(params) == 1 && params[0].Scheme == "digest" {
+				p := params[0].Params
+				ha1 := md5hex("alice:dashboard:secret")
+				ha2 := md5hex(r.Method + ":" + p["uri"])
+				authorized = p["nonce"] == nonce && p["response"] == md5hex(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], "auth", ha2}, ":"))
+			}
+			w.Header().Set("WWW-Authenticate", `Digest realm="dashboard", qop="auth,auth-int", nonce="`+nonce+`", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+		case "/api":
+			authorized = r.Header.Get("Authorization") == "Bearer t0ken"
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		}
+		if !authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("<html><body>Login required</body></html>"))
+			return
+		}
+		w.Write([]byte("<html><body>Welcome to the protected area of the site</body></html>"))
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+
+	client := NewClient()
+	ctx := context.Background()
+	var statusErr *HTTPStatusError
+	if _, err := client.Fetch(ctx, server.URL+"/basic", FetchOptions{}); !errors.As(err, &statusErr) || statusErr.StatusCode != 401 {
+		t.Fatalf("expected 401 without credentials, got %v", err)
+	}
+	if challenge, ok := PreferredChallenge(ParseAuthChallenges(statusErr.Page.Header)); !ok || challenge.Scheme != "basic" || challenge.Realm() != "wiki" {
+		t.Fatalf("unexpected challenge %+v", challenge)
+	}
+
+	client.SetCredentials(host.Hostname(), Credentials{Username: "alice", Password: "secret"})
+	for _, path := range []string{"/basic", "/digest", "/digest"} {
+		if _, err := client.Fetch(ctx, server.URL+path, FetchOptions{}); err != nil {
+			t.Fatalf("%s with credentials: %v", path, err)
+		}
+	}
+	client.SetCredentials(host.Hostname(), Credentials{Token: "t0ken"})
+	if _, err := client.Fetch(ctx, server.URL+"/api", FetchOptions{}); err != nil {
+		t.Fatalf("bearer token: %v", err)
+	}
+
+	store := NewCredentialStore()
+	entries, err := parseNetrc(strings.NewReader("machine wiki.corp.example login bob password pw\ndefault login anon password x\n"))
+	if err != nil || len(entries) != 1 || entries[0].login != "bob" || entries[0].password != "pw" {
+		t.Fatalf("parseNetrc = %+v, %v", entries, err)
+	}
+	if err := store.LoadEnv("api.example.com=bearer:abc,.corp.example=carol:pw"); err != nil {
+		t.Fatal(err)
+	}
+	if credentials, ok := store.Lookup("wiki.corp.example"); !ok || credentials.Username != "carol" {
+		t.Fatalf("domain lookup = %+v, %v", credentials, ok)
+	}
+	if _, ok := store.Lookup("example.com"); ok {
+		t.Fatalf("credentials leaked to the parent domain")
+	}
+}
+
+// recordingTransport answers every request with 200 and keeps the Authorization headers sent
+type recordingTransport struct {
+	sent []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.sent = append(t.sent, req.URL.Scheme+"://"+req.URL.Host+" "+req.Header.Get("Authorization"))
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header), Request: req}, nil
+}
+
+// TestAuthOverPlainHTTP checks that credentials for a host are not sent to its
+// plain http origin unless marked insecure
+func TestAuthOverPlainHTTP(t *testing.T) {
+	store := NewCredentialStore()
+	store.Set("wiki.example", Credentials{Token: "t0ken"})
+	store.Set("legacy.example", Credentials{Token: "old", Insecure: true})
+	next := &recordingTransport{}
+	transport := &authTransport{store: store, next: next}
+	for _, target := range []string{"https://wiki.example/", "http://wiki.example/", "http://wiki.example:8443/", "http://legacy.example/"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "https://wiki.example Bearer t0ken|http://wiki.example |http://wiki.example:8443 |http://legacy.example Bearer old"
+	if got := strings.Join(next.sent, "|"); got != want {
+		t.Fatalf("sent %s\nwant %s", got, want)
+	}
+}
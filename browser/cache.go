@@ -0,0 +1,536 @@
+package browser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheStatusHeader is added to every response passing through the cache layer
+// so callers can tell where the response came from
+const cacheStatusHeader = "X-Brauser-Cache"
+
+// Values of the cache status header
+const (
+	CacheMiss        = "MISS"        // Fetched from the origin
+	CacheHit         = "HIT"         // Served fresh from the cache
+	CacheRevalidated = "REVALIDATED" // Origin answered 304 Not Modified
+	CacheOffline     = "OFFLINE"     // Served from the cache in offline mode, possibly stale
+)
+
+// heuristicallyCacheable lists the status codes RFC 9110 allows to be cached
+// without explicit freshness information
+var heuristicallyCacheable = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// NotCachedError is returned in offline mode when a URL is not in the cache
+type NotCachedError struct {
+	URL string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("offline: %s is not in the cache", e.URL)
+}
+
+// HTTPCache is a private on-disk HTTP cache following RFC 9111. Responses are
+// keyed by URL and the request headers named in their Vary header, kept fresh
+// according to Cache-Control and Expires, and revalidated with If-None-Match
+// and If-Modified-Since once stale.
+type HTTPCache struct {
+	dir     string
+	mu      sync.Mutex
+	offline bool
+}
+
+// cacheEntry is the metadata stored next to a cached response body
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code"`
+	Status       string      `json:"status"`
+	Header       http.Header `json:"header"`
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+
+	path string // File path prefix of the entry, without extension
+}
+
+// NewHTTPCache creates a cache that stores responses in dir
+func NewHTTPCache(dir string) (*HTTPCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return &HTTPCache{dir: dir}, nil
+}
+
+// DefaultCacheDir returns the per-user directory for the HTTP cache
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %v", err)
+	}
+	return filepath.Join(dir, "brauser", "http"), nil
+}
+
+// SetOffline switches offline mode, in which only cached responses are served
+func (hc *HTTPCache) SetOffline(offline bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.offline = offline
+}
+
+// Offline reports whether offline mode is enabled
+func (hc *HTTPCache) Offline() bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.offline
+}
+
+// Clear removes every stored response
+func (hc *HTTPCache) Clear() error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	entries, err := os.ReadDir(hc.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		os.Remove(filepath.Join(hc.dir, entry.Name()))
+	}
+	return nil
+}
+
+// cacheTransport is the http.RoundTripper layer that answers requests from an HTTPCache
+type cacheTransport struct {
+	cache *HTTPCache
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only GET is cached; unsafe methods also invalidate what we know about the URL
+	if req.Method != http.MethodGet {
+		if t.cache.Offline() {
+			return nil, &NotCachedError{URL: req.URL.String()}
+		}
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+			t.cache.invalidate(req.URL)
+		}
+		return resp, err
+	}
+
+	entry := t.cache.lookup(req)
+
+	if t.cache.Offline() {
+		if entry == nil {
+			return nil, &NotCachedError{URL: req.URL.String()}
+		}
+		return t.cache.response(req, entry, CacheOffline)
+	}
+
+	// Requests that carry their own validators are passed through untouched
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	requestDirectives := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := requestDirectives["no-store"]; ok {
+		return t.next.RoundTrip(req)
+	}
+
+	now := time.Now()
+	if entry != nil && entry.satisfies(requestDirectives, now) {
+		return t.cache.response(req, entry, CacheHit)
+	}
+
+	// Revalidate a stale entry with its validators
+	outgoing := req
+	if entry != nil {
+		etag := entry.Header.Get("ETag")
+		lastModified := entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+
+	if entry != nil && outgoing != req && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		entry.refresh(resp.Header, requestTime, responseTime)
+		if err := t.cache.writeEntry(entry); err != nil {
+			return nil, err
+		}
+		return t.cache.response(req, entry, CacheRevalidated)
+	}
+
+	resp.Header.Set(cacheStatusHeader, CacheMiss)
+	if isStorable(req, resp) {
+		t.cache.store(req, resp, requestTime, responseTime)
+	} else if entry != nil {
+		t.cache.invalidate(req.URL)
+	}
+	return resp, nil
+}
+
+// satisfies reports whether the entry can be served without contacting the origin
+func (e *cacheEntry) satisfies(requestDirectives map[string]string, now time.Time) bool {
+	if _, ok := requestDirectives["no-cache"]; ok {
+		return false
+	}
+	responseDirectives := parseCacheControl(e.Header.Get("Cache-Control"))
+	if _, ok := responseDirectives["no-cache"]; ok {
+		return false
+	}
+
+	age := e.currentAge(now)
+	if maxAge, ok := requestDirectives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil && age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+	return e.freshnessLifetime() > age
+}
+
+// freshnessLifetime computes how long the response stays fresh (RFC 9111 section 4.2.1)
+func (e *cacheEntry) freshnessLifetime() time.Duration {
+	directives := parseCacheControl(e.Header.Get("Cache-Control"))
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	date := e.date()
+	if expires := e.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // An invalid Expires value means the response is already stale
+		}
+		return expiresTime.Sub(date)
+	}
+
+	// Heuristic freshness: 10% of the time since the last modification, at most a day
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable[e.StatusCode] {
+		lifetime := date.Sub(lastModified) / 10
+		if lifetime > 24*time.Hour {
+			lifetime = 24 * time.Hour
+		}
+		return lifetime
+	}
+	return 0
+}
+
+// currentAge computes the age of the response (RFC 9111 section 4.2.3)
+func (e *cacheEntry) currentAge(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+// date returns the Date header of the response, or the time it was received
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// refresh merges the headers of a 304 response into the stored entry
+func (e *cacheEntry) refresh(header http.Header, requestTime, responseTime time.Time) {
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Set-Cookie":
+			continue
+		}
+		e.Header[name] = values
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// isStorable decides whether a response may be written to the cache
+func isStorable(req *http.Request, resp *http.Response) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	if !heuristicallyCacheable[resp.StatusCode] {
+		return false
+	}
+	if _, ok := parseCacheControl(resp.Header.Get("Cache-Control"))["no-store"]; ok {
+		return false
+	}
+	return strings.TrimSpace(resp.Header.Get("Vary")) != "*"
+}
+
+// lookup returns the stored entry matching the request's URL and Vary headers
+func (hc *HTTPCache) lookup(req *http.Request) *cacheEntry {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	vary, err := hc.readVary(req.URL)
+	if err != nil {
+		return nil
+	}
+
+	path := hc.entryPath(req, vary)
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(path + ".body"); err != nil {
+		return nil
+	}
+	entry.path = path
+	return &entry
+}
+
+// response builds an http.Response from a stored entry
+func (hc *HTTPCache) response(req *http.Request, entry *cacheEntry, status string) (*http.Response, error) {
+	body, err := os.Open(entry.path + ".body")
+	if err != nil {
+		return nil, err
+	}
+	info, err := body.Stat()
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(entry.currentAge(time.Now()).Seconds())))
+	header.Set(cacheStatusHeader, status)
+
+	return &http.Response{
+		Status:        entry.Status,
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
+
+// store arranges for the response body to be written to the cache while the
+// caller reads it. The entry is only committed once the body was read completely.
+func (hc *HTTPCache) store(req *http.Request, resp *http.Response, requestTime, responseTime time.Time) {
+	vary := varyHeaders(resp.Header)
+
+	tempFile, err := os.CreateTemp(hc.dir, ".body-*.tmp")
+	if err != nil {
+		return
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie") // Replaying cookies from the cache could resurrect deleted ones
+	header.Del(cacheStatusHeader)
+	entry := &cacheEntry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       header,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+
+	resp.Body = &cachingBody{
+		body: resp.Body,
+		file: tempFile,
+		commit: func() error {
+			hc.mu.Lock()
+			defer hc.mu.Unlock()
+
+			if err := hc.writeVary(req.URL, vary); err != nil {
+				return err
+			}
+			path := hc.entryPath(req, vary)
+			if err := os.Rename(tempFile.Name(), path+".body"); err != nil {
+				return err
+			}
+			return hc.writeJSON(path+".json", entry)
+		},
+	}
+}
+
+// writeEntry updates the metadata of an entry returned by lookup
+func (hc *HTTPCache) writeEntry(entry *cacheEntry) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.writeJSON(entry.path+".json", entry)
+}
+
+// invalidate forgets every variant stored for a URL
+func (hc *HTTPCache) invalidate(u *url.URL) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	os.Remove(filepath.Join(hc.dir, hashKey(u.String())+".vary"))
+}
+
+// readVary returns the request header names the stored responses for a URL vary on
+func (hc *HTTPCache) readVary(u *url.URL) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(hc.dir, hashKey(u.String())+".vary"))
+	if err != nil {
+		return nil, err
+	}
+	var vary []string
+	if err := json.Unmarshal(data, &vary); err != nil {
+		return nil, err
+	}
+	return vary, nil
+}
+
+// writeVary records the Vary header names for a URL
+func (hc *HTTPCache) writeVary(u *url.URL, vary []string) error {
+	return hc.writeJSON(filepath.Join(hc.dir, hashKey(u.String())+".vary"), vary)
+}
+
+// writeJSON atomically replaces a file with the JSON encoding of value
+func (hc *HTTPCache) writeJSON(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(hc.dir, ".meta-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// entryPath returns the file path prefix of the variant selected by the request
+func (hc *HTTPCache) entryPath(req *http.Request, vary []string) string {
+	var key strings.Builder
+	key.WriteString(req.URL.String())
+	for _, name := range vary {
+		key.WriteString("\n" + name + ": " + strings.Join(req.Header.Values(name), ", "))
+	}
+	return filepath.Join(hc.dir, hashKey(req.URL.String())+"-"+hashKey(key.String()))
+}
+
+// varyHeaders returns the sorted canonical header names listed in Vary
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCacheControl splits a Cache-Control header into lower-cased directives
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+// hashKey returns a file-name-safe digest of a cache key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// cachingBody copies a response body to a temporary file as it is read
+type cachingBody struct {
+	body     io.ReadCloser
+	file     *os.File
+	commit   func() error
+	failed   bool
+	finished bool
+}
+
+// Read implements io.Reader
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.failed {
+		if _, writeErr := b.file.Write(p[:n]); writeErr != nil {
+			b.failed = true
+		}
+	}
+	if err == io.EOF && !b.finished {
+		b.finished = true
+		if closeErr := b.file.Close(); closeErr != nil {
+			b.failed = true
+		}
+		if !b.failed && b.commit() != nil {
+			b.failed = true
+		}
+		if b.failed {
+			os.Remove(b.file.Name())
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer and discards a partially read body
+func (b *cachingBody) Close() error {
+	if !b.finished {
+		b.finished = true
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+	return b.body.Close()
+}
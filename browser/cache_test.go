@@ -0,0 +1,75 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCacheFreshnessRevalidationAndOffline checks fresh hits, 304 revalidation and offline mode
+func TestCacheFreshnessRevalidationAndOffline(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte("<html><body>" + r.URL.Path + "</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	if err := client.EnableCache(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(path string, opts FetchOptions) *PageResult {
+		t.Helper()
+		result, err := client.Fetch(context.Background(), server.URL+path, opts)
+		if err != nil {
+			t.Fatalf("fetching %s failed: %v", path, err)
+		}
+		return result
+	}
+
+	if status := fetch("/fresh", FetchOptions{}).CacheStatus; status != CacheMiss {
+		t.Fatalf("first fetch: cache status %q, want %q", status, CacheMiss)
+	}
+	result := fetch("/fresh", FetchOptions{})
+	if result.CacheStatus != CacheHit || requests["/fresh"] != 1 || result.Content != "<html><body>/fresh</body></html>" {
+		t.Fatalf("expected a fresh hit, got %q after %d requests", result.CacheStatus, requests["/fresh"])
+	}
+	if status := fetch("/fresh", FetchOptions{Revalidate: true}).CacheStatus; status != CacheMiss || requests["/fresh"] != 2 {
+		t.Fatalf("reload must go to the origin, got %q", status)
+	}
+
+	fetch("/etag", FetchOptions{})
+	result = fetch("/etag", FetchOptions{})
+	if result.CacheStatus != CacheRevalidated || requests["/etag"] != 2 || result.StatusCode != http.StatusOK {
+		t.Fatalf("expected revalidation, got %q (%d) after %d requests", result.CacheStatus, result.StatusCode, requests["/etag"])
+	}
+
+	client.SetOfflineMode(true)
+	if status := fetch("/etag", FetchOptions{}).CacheStatus; status != CacheOffline || requests["/etag"] != 2 {
+		t.Fatalf("offline fetch: cache status %q after %d requests", status, requests["/etag"])
+	}
+	_, err := client.Fetch(context.Background(), server.URL+"/unknown", FetchOptions{})
+	var notCached *NotCachedError
+	if !errors.As(err, &notCached) || requests["/unknown"] != 0 {
+		t.Fatalf("expected NotCachedError in offline mode, got %v", err)
+	}
+	_, err = client.Fetch(context.Background(), server.URL+"/etag", FetchOptions{Method: http.MethodPost, Body: []byte("q=1"), ContentType: "application/x-www-form-urlencoded"})
+	if !errors.As(err, &notCached) || requests["/etag"] != 2 {
+		t.Fatalf("expected NotCachedError for a POST in offline mode, got %v after %d requests", err, requests["/etag"])
+	}
+}
@@ -0,0 +1,71 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// utf8BOM is the byte order mark some editors put in front of UTF-8 documents
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// metaCharsetPattern finds a charset declared in a meta tag
+var metaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]*charset`)
+
+// decodeToUTF8 converts a response body to UTF-8. The encoding is taken from a
+// byte order mark, the Content-Type charset or a <meta charset> declaration, in
+// that order. Undeclared bodies that are valid UTF-8 are kept as they are, others
+// are read as Windows-1252; JSON is always UTF-8. It returns the converted body
+// and the canonical name of the detected encoding.
+func decodeToUTF8(body []byte, contentType string) ([]byte, string, error) {
+	if !isTextContentType(contentType) {
+		return body, "", nil
+	}
+	// An untyped body is sniffed first so files are kept byte for byte
+	if contentType == "" {
+		if kind := DetectContentKind(contentType, body); kind == KindBinary || kind == KindImage {
+			return body, "", nil
+		}
+	}
+
+	// JSON has no charset parameter and is UTF-8 (RFC 8259)
+	if DetectContentKind(contentType, body) == KindJSON {
+		return bytes.TrimPrefix(body, utf8BOM), "utf-8", nil
+	}
+
+	// DetermineEncoding only looks at the first 1024 bytes, so a body that is
+	// ASCII until then would be taken for Windows-1252
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	declared := certain || metaCharsetPattern.Match(body[:min(len(body), 1024)])
+	if name == "utf-8" || (!declared && utf8.Valid(body)) {
+		return bytes.TrimPrefix(body, utf8BOM), "utf-8", nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, name, fmt.Errorf("failed to decode %s content: %v", name, err)
+	}
+	return bytes.TrimPrefix(decoded, utf8BOM), name, nil
+}
+
+// isTextContentType reports whether a Content-Type describes a textual document
+// that should be transcoded. A missing Content-Type may be text and is sniffed.
+func isTextContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.Contains(mediaType, "html") ||
+		strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "json") ||
+		strings.Contains(mediaType, "javascript")
+}
@@ -0,0 +1,96 @@
+package browser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDecodeToUTF8 checks charset detection from the header, meta tags and BOM
+func TestDecodeToUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		wantCharset string
+		wantText    string
+	}{
+		{
+			name:        "latin1 header",
+			body:        []byte("<p>Gr\xfc\xdfe</p>"),
+			contentType: "text/html; charset=ISO-8859-1",
+			wantCharset: "windows-1252",
+			wantText:    "<p>Grüße</p>",
+		},
+		{
+			name:        "meta charset",
+			body:        []byte("<meta charset=\"windows-1252\"><p>caf\xe9 \x93quoted\x94</p>"),
+			contentType: "text/html",
+			wantCharset: "windows-1252",
+			wantText:    `<meta charset="windows-1252"><p>café “quoted”</p>`,
+		},
+		{
+			name:        "shift_jis http-equiv",
+			body:        []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=Shift_JIS\"><p>\x93\xfa\x96\x7b</p>"),
+			contentType: "",
+			wantCharset: "shift_jis",
+			wantText:    "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=Shift_JIS\"><p>日本</p>",
+		},
+		{
+			name:        "utf8 bom overrides header",
+			body:        []byte("\xef\xbb\xbf<p>Grüße</p>"),
+			contentType: "text/html; charset=iso-8859-1",
+			wantCharset: "utf-8",
+			wantText:    "<p>Grüße</p>",
+		},
+		{
+			name:        "json is utf-8",
+			body:        []byte(`{"padding":"` + strings.Repeat("x", 1100) + `","word":"Grüße"}`),
+			contentType: "application/json; charset=iso-8859-1",
+			wantCharset: "utf-8",
+			wantText:    `{"padding":"` + strings.Repeat("x", 1100) + `","word":"Grüße"}`,
+		},
+		{
+			name:        "utf-8 after the first kilobyte",
+			body:        []byte(strings.Repeat("ascii ", 200) + "Grüße"),
+			contentType: "text/plain",
+			wantCharset: "utf-8",
+			wantText:    strings.Repeat("ascii ", 200) + "Grüße",
+		},
+		{
+			name:        "undeclared latin1",
+			body:        []byte(strings.Repeat("ascii ", 200) + "Gr\xfc\xdfe"),
+			contentType: "text/plain",
+			wantCharset: "windows-1252",
+			wantText:    strings.Repeat("ascii ", 200) + "Grüße",
+		},
+		{
+			name:        "untyped png",
+			body:        []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x93\xfa"),
+			contentType: "",
+			wantCharset: "",
+			wantText:    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x93\xfa",
+		},
+		{
+			name:        "untyped binary",
+			body:        []byte("\x00\x01\x02\xe9\xff binary"),
+			contentType: "",
+			wantCharset: "",
+			wantText:    "\x00\x01\x02\xe9\xff binary",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, charset, err := decodeToUTF8(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if charset != tt.wantCharset {
+				t.Errorf("charset = %q, want %q", charset, tt.wantCharset)
+			}
+			if string(decoded) != tt.wantText {
+				t.Errorf("decoded = %q, want %q", decoded, tt.wantText)
+			}
+		})
+	}
+}
@@ -1,46 +1,149 @@
 package browser
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // Client represents an HTTP client for fetching web pages
 type Client struct {
-	httpClient      *http.Client
-	userAgent       string
-	contentDetector *ContentDetector
-	siteHandlers    *SiteHandlerManager
-	maxRetries      int
-	maxWaitTime     time.Duration
+	httpClient         *http.Client
+	timeout            time.Duration
+	profiles           map[string]HeaderProfile
+	profile            string
+	hostProfiles       []hostProfile
+	headers            http.Header
+	contentDetector    *ContentDetector
+	siteHandlers       *SiteHandlerManager
+	maxRetries         int
+	maxWaitTime        time.Duration
+	maxRetryAfter      time.Duration
+	maxRedirects       int
+	maxClientRedirects int
+	cookieJar          *CookieJar
+	maxDocumentSize    int64
+	maxDownloadSize    int64
+	streamDir          string
+	downloadProgress   DownloadProgress
+	credentials        *CredentialStore
+	baseTransport      *http.Transport
+	insecureTransport  *http.Transport
+	insecureHosts      []string
+	proxy              *proxyConfig
+	ssrfGuard          *SSRFGuard
+	urlPolicy          *URLPolicy
+	replay             http.RoundTripper
+	cache              *HTTPCache
+	harRecorder        *HARRecorder
+	politeness         *PolitenessPolicy
+	robots             *RobotsCache
+	robotsBase         http.RoundTripper // Transport the robots cache was created for
+	robotsMode         RobotsMode
 }
 
 // NewClient creates a new browser client with default settings
 func NewClient() *Client {
-	return &Client{
+	cookieJar := NewCookieJar()
+	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
+	proxy, _ := newProxyConfig("", "")
+	baseTransport.Proxy = proxy.transportProxy
+	client := &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Jar: cookieJar,
 		},
-		userAgent:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.114 Safari/537.36",
-		contentDetector: NewContentDetector(),
-		siteHandlers:    NewSiteHandlerManager(),
-		maxRetries:      3,
-		maxWaitTime:     10 * time.Second,
+		timeout:            10 * time.Second,
+		profiles:           builtinProfiles(),
+		profile:            DefaultProfile,
+		contentDetector:    NewContentDetector(),
+		siteHandlers:       NewSiteHandlerManager(),
+		maxRetries:         3,
+		maxWaitTime:        10 * time.Second,
+		maxRetryAfter:      60 * time.Second,
+		maxRedirects:       10,
+		maxClientRedirects: 5,
+		maxDocumentSize:    DefaultMaxDocumentSize,
+		maxDownloadSize:    1 << 30,
+		cookieJar:          cookieJar,
+		baseTransport:      baseTransport,
+		proxy:              proxy,
 	}
+	client.httpClient.CheckRedirect = client.checkRedirect
+	client.rebuildTransport()
+	return client
+}
+
+// rebuildTransport stacks the optional request layers on top of the base
+// transport. It is called whenever one of the layers is enabled or replaced.
+func (c *Client) rebuildTransport() {
+	var transport http.RoundTripper = c.baseTransport
+	if c.insecureTransport != nil {
+		transport = &insecureHostTransport{hosts: c.insecureHosts, insecure: c.insecureTransport, next: c.baseTransport}
+	}
+	if c.ssrfGuard != nil {
+		transport = &ssrfProxyTransport{guard: c.ssrfGuard, proxy: c.proxy, next: transport}
+	}
+	if c.replay != nil {
+		transport = c.replay
+	}
+	if c.robots == nil || c.robotsBase != transport {
+		c.robots = NewRobotsCache(transport)
+		c.robotsBase = transport
+	}
+	// robots.txt goes through the cache and the HAR recorder as well, so it is
+	// answered offline and captured in recordings
+	c.robots.transport = transport
+	if c.cache != nil {
+		c.robots.transport = &cacheTransport{cache: c.cache, next: c.robots.transport}
+	}
+	if c.harRecorder != nil {
+		c.robots.transport = &harRecordingTransport{recorder: c.harRecorder, limit: c.maxDocumentSize, next: c.robots.transport}
+	}
+	
+	// The politeness layer applies the timeout itself so waiting for a turn is not counted
+	c.httpClient.Timeout = c.timeout
+	if c.politeness != nil {
+		transport = newPoliteTransport(*c.politeness, c.timeout, c.robots.crawlDelay, transport)
+		c.httpClient.Timeout = 0
+	}
+	if c.credentials != nil {
+		transport = &authTransport{store: c.credentials, next: transport}
+	}
+	if c.cache != nil {
+		transport = &cacheTransport{cache: c.cache, next: transport}
+	}
+	if c.robotsMode != RobotsIgnore {
+		transport = &robotsTransport{robots: c.robots, mode: c.robotsMode, next: transport}
+	}
+	if c.urlPolicy != nil {
+		transport = &policyTransport{policy: c.urlPolicy, next: transport}
+	}
+	if c.harRecorder != nil {
+		transport = &harRecordingTransport{recorder: c.harRecorder, limit: c.maxDocumentSize, next: transport}
+	}
+	transport = &profileTransport{client: c, next: transport}
+	c.httpClient.Transport = newLocalTransport(c.urlPolicy, transport)
 }
 
 // SetTimeout sets the HTTP client timeout
 func (c *Client) SetTimeout(timeout time.Duration) {
-	c.httpClient.Timeout = timeout
+	c.timeout = timeout
+	c.rebuildTransport()
 }
 
-// SetUserAgent sets the User-Agent header
+// SetUserAgent replaces the User-Agent of the session profile, keeping its
+// other headers and its JavaScript fingerprint
 func (c *Client) SetUserAgent(userAgent string) {
-	c.userAgent = userAgent
+	profile := c.profiles[c.profile].Clone("custom")
+	profile.UserAgent = userAgent
+	c.AddProfile(profile)
+	c.profile = profile.Name
 }
 
 // SetMaxRetries sets the maximum number of retry attempts
@@ -53,42 +156,304 @@ func (c *Client) SetMaxWaitTime(waitTime time.Duration) {
 	c.maxWaitTime = waitTime
 }
 
+// SetMaxRetryAfter sets the longest Retry-After delay the client will wait for.
+// Responses asking for a longer delay are returned as errors without retrying.
+func (c *Client) SetMaxRetryAfter(waitTime time.Duration) {
+	c.maxRetryAfter = waitTime
+}
+
+// SetMaxRedirects sets how many HTTP redirects are followed for one request
+func (c *Client) SetMaxRedirects(redirects int) {
+	c.maxRedirects = redirects
+}
+
+// SetMaxClientRedirects sets how many meta refresh and JavaScript redirects
+// Fetch follows; 0 disables following them
+func (c *Client) SetMaxClientRedirects(redirects int) {
+	c.maxClientRedirects = redirects
+}
+
+// SetMaxDocumentSize sets the largest decoded page body loaded into memory.
+// Larger pages fail with a *TooLargeError; 0 disables the limit.
+func (c *Client) SetMaxDocumentSize(size int64) {
+	c.maxDocumentSize = size
+	c.rebuildTransport()
+}
+
+// SetMaxDownloadSize sets the largest file saved by SetStreamDir; 0 disables the limit
+func (c *Client) SetMaxDownloadSize(size int64) {
+	c.maxDownloadSize = size
+}
+
+// SetStreamDir saves binary responses, and text responses declared larger than
+// the document limit, into dir instead of loading them. The result then has an
+// empty Content and the file in SavedPath. An empty dir turns streaming off.
+func (c *Client) SetStreamDir(dir string) {
+	c.streamDir = dir
+}
+
+// EnablePoliteness limits the request rate and concurrency per host and
+// honors Crawl-delay and Retry-After for all requests made by this client
+func (c *Client) EnablePoliteness(policy PolitenessPolicy) {
+	c.politeness = &policy
+	c.rebuildTransport()
+}
+
+// SetRobotsMode sets how requests disallowed by robots.txt are handled.
+// Rules are matched against the groups for the client's User-Agent.
+func (c *Client) SetRobotsMode(mode RobotsMode) {
+	c.robotsMode = mode
+	c.rebuildTransport()
+}
+
+// CookieJar returns the cookie jar shared by all requests of this client
+func (c *Client) CookieJar() *CookieJar {
+	return c.cookieJar
+}
+
+// LoadCookies adds the cookies stored in the given file to the jar
+func (c *Client) LoadCookies(path string) error {
+	return c.cookieJar.Load(path)
+}
+
+// SaveCookies writes all cookies in the jar to the given file
+func (c *Client) SaveCookies(path string) error {
+	return c.cookieJar.Save(path)
+}
+
+// ClearCookies removes all cookies from the jar
+func (c *Client) ClearCookies() {
+	c.cookieJar.Clear()
+}
+
+// EnableCache stores responses in dir and serves them according to their
+// Cache-Control, Expires and validator headers
+func (c *Client) EnableCache(dir string) error {
+	cache, err := NewHTTPCache(dir)
+	if err != nil {
+		return err
+	}
+	c.cache = cache
+	c.rebuildTransport()
+	return nil
+}
+
+// Cache returns the HTTP cache, or nil if caching is disabled
+func (c *Client) Cache() *HTTPCache {
+	return c.cache
+}
+
+// SetOfflineMode serves pages only from the cache. The cache must be enabled first.
+func (c *Client) SetOfflineMode(offline bool) error {
+	if c.cache == nil {
+		return fmt.Errorf("offline mode requires the HTTP cache to be enabled")
+	}
+	c.cache.SetOffline(offline)
+	return nil
+}
+
+// RecordHAR starts recording every request and response into a HAR archive
+func (c *Client) RecordHAR() *HARRecorder {
+	if c.harRecorder == nil {
+		c.harRecorder = NewHARRecorder()
+		c.rebuildTransport()
+	}
+	return c.harRecorder
+}
+
+// ReplayHAR answers all requests from a HAR file instead of the network
+func (c *Client) ReplayHAR(path string) error {
+	har, err := LoadHAR(path)
+	if err != nil {
+		return err
+	}
+	c.replay = NewHARReplayTransport(har)
+	c.rebuildTransport()
+	return nil
+}
+
+// HTTPClient returns the underlying HTTP client so that other fetchers, such as
+// the image renderer, share its cookies, cache and recording
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
 // FetchPage fetches the content of the given URL and returns it as a string
 func (c *Client) FetchPage(url string) (string, error) {
-	return c.FetchPageWithRetry(url, true)
+	return c.FetchPageContext(context.Background(), url)
+}
+
+// FetchPageContext is like FetchPage but stops as soon as ctx is done
+func (c *Client) FetchPageContext(ctx context.Context, url string) (string, error) {
+	return c.FetchPageWithRetryContext(ctx, url, true)
 }
 
 // FetchPageWithRetry fetches content with optional retry logic for dynamic content
 func (c *Client) FetchPageWithRetry(url string, enableRetry bool) (string, error) {
-	var lastContent string
+	return c.FetchPageWithRetryContext(context.Background(), url, enableRetry)
+}
+
+// FetchPageWithRetryContext is like FetchPageWithRetry but aborts the in-flight
+// request and any wait between retries as soon as ctx is done
+func (c *Client) FetchPageWithRetryContext(ctx context.Context, url string, enableRetry bool) (string, error) {
+	result, err := c.Fetch(ctx, url, FetchOptions{EnableRetry: enableRetry})
+	if err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
+
+// Fetch loads a page and returns the content together with response metadata,
+// per-attempt timing and the content analysis. Failures are reported with the
+// typed errors from errors.go; 4xx and 5xx responses return an *HTTPStatusError
+// after retrying the statuses that IsRetryable allows. HTTP, meta refresh and
+// JavaScript redirects are followed and recorded in the redirect chain.
+func (c *Client) Fetch(ctx context.Context, url string, opts FetchOptions) (*PageResult, error) {
+	start := time.Now()
+	var chain []Redirect
+	visited := map[string]bool{}
+	current := url
+	
+	for {
+		if err := c.urlPolicy.Check(current); err != nil {
+			return nil, err
+		}
+		result, err := c.fetch(ctx, current, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Like a browser, client-side redirects after a form submission load the target with GET
+		opts.Method, opts.Body, opts.ContentType = "", nil, ""
+		chain = append(chain, result.RedirectChain...)
+		visited[current] = true
+		for _, hop := range result.RedirectChain {
+			visited[hop.To] = true
+		}
+		
+		target, kind := result.clientRedirect, result.clientRedirectKind
+		if target != "" && visited[target] {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Stopped a redirect loop back to %s", target))
+			target = ""
+		}
+		if target != "" && countClientRedirects(chain) >= c.maxClientRedirects {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Stopped after %d client-side redirects at %s", c.maxClientRedirects, target))
+			target = ""
+		}
+		if target == "" {
+			result.URL = url
+			result.RedirectChain = chain
+			result.Duration = time.Since(start)
+			return result, nil
+		}
+		
+		log.Printf("Following %s redirect to %s", kind, target)
+		chain = append(chain, Redirect{From: result.FinalURL, To: target, Kind: kind})
+		current = target
+	}
+}
+
+// countClientRedirects counts the meta refresh and JavaScript hops of a chain
+func countClientRedirects(chain []Redirect) int {
+	count := 0
+	for _, hop := range chain {
+		if hop.Kind != RedirectHTTP {
+			count++
+		}
+	}
+	return count
+}
+
+// fetch loads a single URL with retries, without following client-side redirects
+func (c *Client) fetch(ctx context.Context, url string, opts FetchOptions) (*PageResult, error) {
+	start := time.Now()
+	result := &PageResult{URL: url}
+	maxRetries := c.maxRetries
+	if !opts.idempotent() {
+		maxRetries = 0
+	}
 	
 	// Check for site-specific handler
 	siteHandler := c.siteHandlers.GetHandler(url)
+	if siteHandler != nil {
+		result.SiteHandler = siteHandlerName(siteHandler)
+	}
 	
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
 		// Fetch the page content
-		content, err := c.fetchPageOnce(url)
+		// Retries must not be answered by the cached copy that triggered them
+		attemptStart := time.Now()
+		page, err := c.fetchPageOnce(ctx, url, opts, opts.Revalidate || attempt > 0)
 		if err != nil {
-			return "", err
+			attemptInfo := Attempt{Duration: time.Since(attemptStart), Err: err}
+			if page != nil {
+				attemptInfo.StatusCode = page.StatusCode
+				attemptInfo.ContentLength = len(page.Content)
+			}
+			result.Attempts = append(result.Attempts, attemptInfo)
+			
+			waitTime, retry := c.retryDelay(err, attempt)
+			if !retry || attempt == maxRetries {
+				return nil, err
+			}
+			
+			log.Printf("Request failed (%v), waiting %v before retry %d/%d", err, waitTime, attempt+1, maxRetries)
+			if err := sleepContext(ctx, waitTime); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		
-		lastContent = content
+		result.FinalURL = page.FinalURL
+		result.StatusCode = page.StatusCode
+		result.Status = page.Status
+		result.Header = page.Header
+		result.ContentType = page.ContentType
+		result.Charset = page.Charset
+		result.CacheStatus = page.CacheStatus
+		result.Content = page.Content
+		result.Warnings = page.Warnings
+		result.RedirectChain = page.RedirectChain
+		result.SavedPath = page.SavedPath
+		result.SavedSize = page.SavedSize
+		result.ContentRewritten = false
+		result.Retries = attempt
+		result.Attempts = append(result.Attempts, Attempt{
+			StatusCode:    page.StatusCode,
+			ContentLength: len(page.Content),
+			Duration:      time.Since(attemptStart),
+		})
+		result.Duration = time.Since(start)
 		
 		// Apply site-specific processing if available
 		if siteHandler != nil {
-			processedContent, err := siteHandler.ProcessContent(content, url)
-			if err == nil {
-				content = processedContent
-				lastContent = content
+			processedContent, err := siteHandler.ProcessContent(page.Content, url)
+			if err == nil && processedContent != page.Content {
+				result.Content = processedContent
+				result.ContentRewritten = true
+			}
+		}
+		content := result.Content
+		
+		// Client-side redirects are followed by Fetch instead of waiting on the interstitial
+		if c.maxClientRedirects > 0 && isHTMLContentType(result.ContentType) {
+			target, kind, delay := findClientRedirect(content, result.FinalURL)
+			if kind == RedirectMetaRefresh && delay > maxRefreshDelay {
+				content = refreshLink(content, target, delay)
+				result.Content = content
+			} else if target != "" {
+				result.clientRedirect, result.clientRedirectKind = target, kind
+				return result, nil
 			}
 		}
 		
-		// Analyze content if retry is enabled
-		if !enableRetry {
-			return content, nil
+		// Analyze content if retry is enabled; only HTML pages fill in after loading
+		if !opts.EnableRetry || result.SavedPath != "" || result.Kind() != KindHTML {
+			return result, nil
 		}
 		
 		analysis := c.contentDetector.AnalyzeContent(content)
+		analysis.Charset = result.Charset
+		result.Analysis = analysis
 		
 		// Log analysis results for debugging
 		if attempt == 0 {
@@ -101,9 +466,10 @@ func (c *Client) FetchPageWithRetry(url string, enableRetry bool) (string, error
 			siteNeedsRetry = siteHandler.RequiresRetry(content)
 		}
 		
-		// If content is loaded or we've reached max retries, return
-		if (analysis.IsLoaded && !siteNeedsRetry) || attempt == c.maxRetries {
-			return content, nil
+		// If content is loaded or we've reached max retries, return.
+		// Local documents do not change by waiting for them.
+		if (analysis.IsLoaded && !siteNeedsRetry) || attempt == maxRetries || isLocalURL(result.FinalURL) {
+			return result, nil
 		}
 		
 		// Wait before retrying if content needs more time
@@ -122,54 +488,170 @@ func (c *Client) FetchPageWithRetry(url string, enableRetry bool) (string, error
 				waitTime = c.maxWaitTime
 			}
 			
-			log.Printf("Content not fully loaded, waiting %v before retry %d/%d", waitTime, attempt+1, c.maxRetries)
-			time.Sleep(waitTime)
+			log.Printf("Content not fully loaded, waiting %v before retry %d/%d", waitTime, attempt+1, maxRetries)
+			if err := sleepContext(ctx, waitTime); err != nil {
+				return nil, err
+			}
 		} else {
 			// No retry needed, return current content
-			return content, nil
+			return result, nil
 		}
 	}
 	
-	return lastContent, nil
+	return result, nil
 }
 
-// fetchPageOnce performs a single HTTP request to fetch page content
-func (c *Client) fetchPageOnce(url string) (string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// fetchPageOnce performs a single HTTP request to fetch page content. The
+// content of the returned result is converted to UTF-8. With revalidate set, a
+// cached copy is only used after the origin confirmed it is still current.
+func (c *Client) fetchPageOnce(ctx context.Context, url string, opts FetchOptions, revalidate bool) (*PageResult, error) {
+	ctx, redirects := withRedirectRecorder(ctx)
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var requestBody io.Reader
+	if opts.Body != nil {
+		requestBody = bytes.NewReader(opts.Body) // Lets redirects and auth retries send the body again
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, requestBody)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if opts.ContentType != "" {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+	if opts.Referer != "" && !isLocalURL(opts.Referer) {
+		if referer := refererFor(opts.Referer, url); referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+		if origin := originOf(opts.Referer); origin != "" && method == http.MethodPost {
+			req.Header.Set("Origin", origin)
+		}
 	}
 	
-	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	// User-Agent, Accept and the other browser headers come from the host's profile
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if revalidate {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
 	
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, classifyError(ctx, url, err)
 	}
 	defer resp.Body.Close()
 	
-	// Handle gzip decompression
-	var reader io.Reader = resp.Body
-	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
-		gzipReader, err := gzip.NewReader(resp.Body)
+	// Remove gzip, deflate and brotli content codings, including stacked ones
+	reader, err := decodeContentEncoding(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	
+	// Binary and oversized downloads go to disk instead of memory when a directory is set.
+	// The first bytes tell untyped binaries apart from pages.
+	buffered := bufio.NewReader(reader)
+	head, _ := buffered.Peek(512)
+	if c.streamDir != "" && resp.StatusCode < 300 && shouldStream(resp, head, c.maxDocumentSize) {
+		path, size, err := streamToFile(c.streamDir, resp, buffered, c.maxDownloadSize, c.downloadProgress)
 		if err != nil {
-			return "", err
+			return nil, classifyError(ctx, url, err)
 		}
-		defer gzipReader.Close()
-		reader = gzipReader
+		return &PageResult{
+			URL:           url,
+			FinalURL:      resp.Request.URL.String(),
+			StatusCode:    resp.StatusCode,
+			Status:        resp.Status,
+			Header:        resp.Header,
+			ContentType:   resp.Header.Get("Content-Type"),
+			CacheStatus:   resp.Header.Get(cacheStatusHeader),
+			SavedPath:     path,
+			SavedSize:     size,
+			RedirectChain: redirects.chain,
+		}, nil
 	}
 	
-	body, err := io.ReadAll(reader)
+	// The limit applies to the decoded body so compressed responses cannot expand without bound
+	if err := CheckContentLength(resp, c.maxDocumentSize); err != nil {
+		return nil, err
+	}
+	body, err := ReadLimited(buffered, c.maxDocumentSize, resp.Request.URL.String())
 	if err != nil {
-		return "", err
+		return nil, classifyError(ctx, url, err)
 	}
 	
-	return string(body), nil
+	// Transcode to UTF-8 before detection, site handlers and rendering see the page
+	contentType := resp.Header.Get("Content-Type")
+	body, charset, err := decodeToUTF8(body, contentType)
+	if err != nil {
+		log.Printf("Charset conversion failed for %s: %v", url, err)
+	}
+	
+	page := &PageResult{
+		URL:           url,
+		FinalURL:      resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentType:   contentType,
+		Charset:       charset,
+		CacheStatus:   resp.Header.Get(cacheStatusHeader),
+		Content:       string(body),
+		RedirectChain: redirects.chain,
+	}
+	if resp.Header.Get(robotsHeader) == "disallowed" {
+		page.Warnings = append(page.Warnings, "robots.txt disallows this page for "+c.userAgent(resp.Request.URL.Hostname()))
+	}
+	
+	// Error pages are reported as errors but keep the page for callers that want to show it
+	if resp.StatusCode >= 400 {
+		return page, &HTTPStatusError{
+			URL:        page.FinalURL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Page:       page,
+		}
+	}
+	
+	return page, nil
+}
+
+// retryDelay decides whether a failed request is retried and how long to wait first.
+// A Retry-After header is honored up to maxRetryAfter; otherwise the wait doubles
+// with every attempt, capped at maxWaitTime.
+func (c *Client) retryDelay(err error, attempt int) (time.Duration, bool) {
+	if !IsRetryable(err) {
+		return 0, false
+	}
+	
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if statusErr.RetryAfter > c.maxRetryAfter {
+			return 0, false
+		}
+		return statusErr.RetryAfter, true
+	}
+	
+	waitTime := time.Second << attempt
+	if waitTime > c.maxWaitTime {
+		waitTime = c.maxWaitTime
+	}
+	return waitTime, true
+}
+
+// sleepContext waits for the given duration or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // logContentAnalysis logs the results of content analysis for debugging
@@ -204,11 +686,20 @@ func (c *Client) GetSiteHandlers() *SiteHandlerManager {
 
 // FetchPageWithEnhancedDetection fetches content with full content detection and site-specific handling
 func (c *Client) FetchPageWithEnhancedDetection(url string) (string, *ContentAnalysis, error) {
-	content, err := c.FetchPageWithRetry(url, true)
+	return c.FetchPageWithEnhancedDetectionContext(context.Background(), url)
+}
+
+// FetchPageWithEnhancedDetectionContext is like FetchPageWithEnhancedDetection but stops as soon as ctx is done
+func (c *Client) FetchPageWithEnhancedDetectionContext(ctx context.Context, url string) (string, *ContentAnalysis, error) {
+	result, err := c.Fetch(ctx, url, FetchOptions{EnableRetry: true})
 	if err != nil {
 		return "", nil, err
 	}
 	
-	analysis := c.contentDetector.AnalyzeContent(content)
-	return content, analysis, nil
+	analysis := result.Analysis
+	if analysis == nil {
+		analysis = c.contentDetector.AnalyzeContent(result.Content)
+		analysis.Charset = result.Charset
+	}
+	return result.Content, analysis, nil
 }
\ No newline at end of file
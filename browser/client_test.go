@@ -0,0 +1,108 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFetchContextCancelsRetryWait checks that a cancelled context interrupts
+// the wait between content retries instead of sleeping it out
+func TestFetchContextCancelsRetryWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Just a moment...</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.FetchPageWithRetryContext(ctx, server.URL, true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("fetch took %v after the context ended", elapsed)
+	}
+}
+
+// TestFetchCallerDeadlineIsNotRetried checks that a request cut off by the
+// caller's deadline fails with the context error and is not retried, while the
+// client's own timeout is a retryable TimeoutError
+func TestFetchCallerDeadlineIsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := client.Fetch(ctx, server.URL, FetchOptions{EnableRetry: true})
+	var timeoutErr *TimeoutError
+	if err != context.DeadlineExceeded || errors.As(err, &timeoutErr) || IsRetryable(err) {
+		t.Fatalf("expected the bare deadline error, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("expected one request, got %d", requests.Load())
+	}
+
+	client.SetTimeout(100 * time.Millisecond)
+	client.SetMaxRetries(0)
+	_, err = client.Fetch(context.Background(), server.URL, FetchOptions{})
+	if !errors.As(err, &timeoutErr) || !IsRetryable(err) {
+		t.Fatalf("expected a retryable TimeoutError, got %v", err)
+	}
+}
+
+// TestFetchStatusErrors checks that 404 fails immediately with a typed error
+// while 503 is retried after the Retry-After delay
+func TestFetchStatusErrors(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/busy" && requests[r.URL.Path] == 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("<html><body>ok</body></html>"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+
+	_, err := client.Fetch(context.Background(), server.URL+"/missing", FetchOptions{})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected HTTPStatusError 404, got %v", err)
+	}
+	if IsRetryable(err) || requests["/missing"] != 1 {
+		t.Fatalf("404 must not be retried, got %d requests", requests["/missing"])
+	}
+
+	result, err := client.Fetch(context.Background(), server.URL+"/busy", FetchOptions{})
+	if err != nil {
+		t.Fatalf("expected 503 to be retried, got %v", err)
+	}
+	if requests["/busy"] != 2 || result.Retries != 1 || len(result.Attempts) != 2 {
+		t.Fatalf("unexpected retries: %d requests, %+v", requests["/busy"], result.Attempts)
+	}
+	if result.Attempts[0].StatusCode != http.StatusServiceUnavailable || result.Attempts[0].Duration <= 0 {
+		t.Fatalf("first attempt not recorded: %+v", result.Attempts[0])
+	}
+}
@@ -55,6 +55,7 @@ type ContentAnalysis struct {
 	LoadingIndicators  []string
 	SuggestedWaitTime  time.Duration
 	RequiresRetry      bool
+	Charset            string // Encoding the document was converted from, set by the client
 }
 
 // AnalyzeContent analyzes the HTML content to determine its state
@@ -91,12 +92,12 @@ func (cd *ContentDetector) AnalyzeContent(htmlContent string) *ContentAnalysis {
 	// Determine if content is loaded
 	analysis.IsLoaded = cd.isContentLoaded(analysis)
 
-	// Suggest wait time if needed
-	analysis.SuggestedWaitTime = cd.calculateWaitTime(analysis)
-
 	// Determine if retry is needed
 	analysis.RequiresRetry = !analysis.IsLoaded && (analysis.IsLoadingPage || analysis.ContentLength < cd.minContentLength)
 
+	// Suggest wait time if needed (depends on RequiresRetry)
+	analysis.SuggestedWaitTime = cd.calculateWaitTime(analysis)
+
 	return analysis
 }
 
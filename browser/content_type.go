@@ -0,0 +1,87 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentKind is the kind of document a response contains, which decides how it is rendered
+type ContentKind int
+
+const (
+	KindHTML   ContentKind = iota // HTML or XHTML page
+	KindText                      // Plain text shown as is
+	KindJSON                      // JSON pretty-printed
+	KindXML                       // XML, RSS and Atom shown as a tree
+	KindImage                     // Image rendered as ASCII art
+	KindBinary                    // Anything else, offered for download
+)
+
+// String returns a short name for the kind
+func (k ContentKind) String() string {
+	switch k {
+	case KindHTML:
+		return "html"
+	case KindText:
+		return "text"
+	case KindJSON:
+		return "json"
+	case KindXML:
+		return "xml"
+	case KindImage:
+		return "image"
+	}
+	return "binary"
+}
+
+// DetectContentKind classifies a response by its Content-Type, sniffing the
+// body when the type is missing or generic
+func DetectContentKind(contentType string, body []byte) ContentKind {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	switch {
+	case mediaType == "" || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream":
+		return sniffContentKind(body)
+	case strings.Contains(mediaType, "html"):
+		return KindHTML
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return KindJSON
+	case strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml"):
+		return KindXML
+	case strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml":
+		return KindImage
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/javascript":
+		return KindText
+	}
+	return KindBinary
+}
+
+// sniffContentKind guesses the kind of an untyped body from its first bytes
+func sniffContentKind(body []byte) ContentKind {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return KindHTML
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return KindJSON
+	}
+	sniffed := http.DetectContentType(body)
+	if strings.HasPrefix(sniffed, "application/octet-stream") {
+		return KindBinary
+	}
+	if strings.HasPrefix(sniffed, "text/xml") && !bytes.Contains(bytes.ToLower(trimmed[:min(len(trimmed), 512)]), []byte("<html")) {
+		return KindXML
+	}
+	return DetectContentKind(sniffed, nil)
+}
+
+// Kind returns the content kind of the page
+func (r *PageResult) Kind() ContentKind {
+	return DetectContentKind(r.ContentType, []byte(r.Content))
+}
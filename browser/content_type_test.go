@@ -0,0 +1,35 @@
+package browser
+
+import "testing"
+
+// TestDetectContentKind checks dispatch on Content-Type and sniffing of untyped bodies
+func TestDetectContentKind(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		want        ContentKind
+	}{
+		{"text/html; charset=utf-8", "", KindHTML},
+		{"application/xhtml+xml", "", KindHTML},
+		{"text/plain", "{}", KindText},
+		{"application/json", "", KindJSON},
+		{"application/vnd.api+json", "", KindJSON},
+		{"application/rss+xml", "", KindXML},
+		{"text/xml", "", KindXML},
+		{"image/png", "", KindImage},
+		{"image/svg+xml", "", KindXML},
+		{"application/pdf", "%PDF-1.7", KindBinary},
+		{"", "", KindHTML},
+		{"", "<!DOCTYPE html><p>hi</p>", KindHTML},
+		{"", ` [1, {"a": 2}] `, KindJSON},
+		{"application/octet-stream", `<?xml version="1.0"?><feed/>`, KindXML},
+		{"", string(png), KindImage},
+		{"", "\x00\x01\x02\x03", KindBinary},
+		{"", "just some words", KindText},
+	} {
+		if got := DetectContentKind(tc.contentType, []byte(tc.body)); got != tc.want {
+			t.Errorf("DetectContentKind(%q, %q) = %v, want %v", tc.contentType, tc.body, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package browser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieFormat identifies an on-disk cookie file format
+type CookieFormat int
+
+const (
+	// CookieFormatJSON stores cookies as a JSON array of StoredCookie values
+	CookieFormatJSON CookieFormat = iota
+	// CookieFormatNetscape stores cookies in the curl/wget cookies.txt format
+	CookieFormatNetscape
+)
+
+// StoredCookie is the serializable form of a cookie held by the jar
+type StoredCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Expires  int64  `json:"expires,omitempty"` // Unix seconds, 0 for session cookies
+	Secure   bool   `json:"secure,omitempty"`
+	HttpOnly bool   `json:"httpOnly,omitempty"`
+	HostOnly bool   `json:"hostOnly,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+// CookieJar is an http.CookieJar that keeps track of every stored cookie so
+// the jar can be inspected, exported and persisted between runs
+type CookieJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]StoredCookie
+}
+
+// NewCookieJar creates an empty cookie jar using the public suffix list
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &CookieJar{
+		jar:     jar,
+		cookies: make(map[string]StoredCookie),
+	}
+}
+
+// SetCookies implements http.CookieJar
+func (cj *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+
+	cj.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored, ok := storedCookieFromResponse(u, cookie, now)
+		if !ok {
+			continue
+		}
+		key := cookieKey(stored)
+		if stored.Expires != 0 && stored.Expires <= now.Unix() {
+			delete(cj.cookies, key)
+			continue
+		}
+		cj.cookies[key] = stored
+	}
+}
+
+// Cookies implements http.CookieJar
+func (cj *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	return cj.jar.Cookies(u)
+}
+
+// All returns every unexpired cookie in the jar, sorted by domain, path and name
+func (cj *CookieJar) All() []StoredCookie {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+
+	now := time.Now().Unix()
+	all := make([]StoredCookie, 0, len(cj.cookies))
+	for key, cookie := range cj.cookies {
+		if cookie.Expires != 0 && cookie.Expires <= now {
+			delete(cj.cookies, key)
+			continue
+		}
+		all = append(all, cookie)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Domain != all[j].Domain {
+			return all[i].Domain < all[j].Domain
+		}
+		if all[i].Path != all[j].Path {
+			return all[i].Path < all[j].Path
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Clear removes all cookies from the jar
+func (cj *CookieJar) Clear() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+
+	cj.jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	cj.cookies = make(map[string]StoredCookie)
+}
+
+// Add stores a previously serialized cookie in the jar, skipping expired ones
+func (cj *CookieJar) Add(stored StoredCookie) {
+	if stored.Name == "" || stored.Domain == "" {
+		return
+	}
+	if stored.Expires != 0 && stored.Expires <= time.Now().Unix() {
+		return
+	}
+	if stored.Path == "" {
+		stored.Path = "/"
+	}
+	stored.Domain = strings.TrimPrefix(strings.ToLower(stored.Domain), ".")
+
+	scheme := "http"
+	if stored.Secure {
+		scheme = "https"
+	}
+	u := &url.URL{Scheme: scheme, Host: stored.Domain, Path: stored.Path}
+
+	cookie := &http.Cookie{
+		Name:     stored.Name,
+		Value:    stored.Value,
+		Path:     stored.Path,
+		Secure:   stored.Secure,
+		HttpOnly: stored.HttpOnly,
+		SameSite: parseSameSite(stored.SameSite),
+	}
+	if !stored.HostOnly {
+		cookie.Domain = stored.Domain
+	}
+	if stored.Expires != 0 {
+		cookie.Expires = time.Unix(stored.Expires, 0)
+	}
+
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.jar.SetCookies(u, []*http.Cookie{cookie})
+	cj.cookies[cookieKey(stored)] = stored
+}
+
+// Load reads cookies from a file, picking the format from the file extension.
+// A missing file is not an error so that new sessions start with an empty jar.
+func (cj *CookieJar) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open cookie file: %v", err)
+	}
+	defer file.Close()
+
+	return cj.Import(file, CookieFormatForPath(path))
+}
+
+// Save writes all cookies to a file, picking the format from the file extension
+func (cj *CookieJar) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create cookie directory: %v", err)
+		}
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated jar
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".cookies-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cookie file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if err := cj.Export(tempFile, CookieFormatForPath(path)); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write cookie file: %v", err)
+	}
+	if err := os.Chmod(tempFile.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to write cookie file: %v", err)
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// Import reads cookies in the given format and adds them to the jar
+func (cj *CookieJar) Import(r io.Reader, format CookieFormat) error {
+	switch format {
+	case CookieFormatNetscape:
+		return cj.importNetscape(r)
+	default:
+		var stored []StoredCookie
+		if err := json.NewDecoder(r).Decode(&stored); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to parse cookie JSON: %v", err)
+		}
+		for _, cookie := range stored {
+			cj.Add(cookie)
+		}
+		return nil
+	}
+}
+
+// Export writes all cookies in the given format
+func (cj *CookieJar) Export(w io.Writer, format CookieFormat) error {
+	cookies := cj.All()
+
+	switch format {
+	case CookieFormatNetscape:
+		return exportNetscape(w, cookies)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(cookies); err != nil {
+			return fmt.Errorf("failed to encode cookies: %v", err)
+		}
+		return nil
+	}
+}
+
+// importNetscape parses a cookies.txt file as written by curl, wget and browsers
+func (cj *CookieJar) importNetscape(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+
+		expires, _ := strconv.ParseInt(fields[4], 10, 64)
+		cj.Add(StoredCookie{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Expires:  expires,
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			HttpOnly: httpOnly,
+		})
+	}
+	return scanner.Err()
+}
+
+// exportNetscape writes cookies in the cookies.txt format
+func exportNetscape(w io.Writer, cookies []StoredCookie) error {
+	writer := bufio.NewWriter(w)
+	writer.WriteString("# Netscape HTTP Cookie File\n")
+	writer.WriteString("# Written by Brauser\n\n")
+
+	for _, cookie := range cookies {
+		domain := cookie.Domain
+		includeSubdomains := "FALSE"
+		if !cookie.HostOnly {
+			domain = "." + domain
+			includeSubdomains = "TRUE"
+		}
+		if cookie.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		secure := "FALSE"
+		if cookie.Secure {
+			secure = "TRUE"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, cookie.Path, secure, cookie.Expires, cookie.Name, cookie.Value)
+	}
+	return writer.Flush()
+}
+
+// CookieFormatForPath returns the Netscape format for .txt files and JSON otherwise
+func CookieFormatForPath(path string) CookieFormat {
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		return CookieFormatNetscape
+	}
+	return CookieFormatJSON
+}
+
+// SessionCookiePath returns the cookie file used for a named browsing session
+func SessionCookiePath(session string) (string, error) {
+	if session == "" || strings.ContainsAny(session, `/\`) || session == "." || session == ".." {
+		return "", fmt.Errorf("invalid session name: %q", session)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %v", err)
+	}
+	return filepath.Join(home, ".brauser", "sessions", session, "cookies.json"), nil
+}
+
+// storedCookieFromResponse normalizes a Set-Cookie value the way the jar stores
+// it, and rejects the cookies the jar rejects
+func storedCookieFromResponse(u *url.URL, cookie *http.Cookie, now time.Time) (StoredCookie, bool) {
+	host := strings.ToLower(u.Hostname())
+	if cookie.Name == "" || host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return StoredCookie{}, false
+	}
+
+	stored := StoredCookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		SameSite: sameSiteName(cookie.SameSite),
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+	if domain == "" {
+		stored.Domain = host
+		stored.HostOnly = true
+	} else {
+		// Mirror the jar, which rejects cookies for domains the host does not
+		// belong to, and turns cookies for a public suffix into host-only
+		// cookies when the host is that suffix itself
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return StoredCookie{}, false
+		}
+		suffix := publicsuffix.List.PublicSuffix(domain)
+		switch {
+		case net.ParseIP(host) != nil:
+			if host != domain {
+				return StoredCookie{}, false
+			}
+			stored.Domain = host
+			stored.HostOnly = true
+		case suffix != "" && !strings.HasSuffix(domain, "."+suffix):
+			if host != domain {
+				return StoredCookie{}, false
+			}
+			stored.Domain = host
+			stored.HostOnly = true
+		default:
+			stored.Domain = domain
+		}
+	}
+
+	if stored.Path == "" || !strings.HasPrefix(stored.Path, "/") {
+		stored.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case cookie.MaxAge < 0:
+		stored.Expires = now.Unix() - 1
+	case cookie.MaxAge > 0:
+		stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second).Unix()
+	case !cookie.Expires.IsZero():
+		stored.Expires = cookie.Expires.Unix()
+	}
+
+	return stored, true
+}
+
+// defaultCookiePath implements the default-path algorithm of RFC 6265 section 5.1.4
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// cookieKey identifies a cookie by domain, path and name
+func cookieKey(cookie StoredCookie) string {
+	return cookie.Domain + ";" + cookie.Path + ";" + cookie.Name
+}
+
+// sameSiteName converts an http.SameSite value to its attribute name
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return ""
+	}
+}
+
+// parseSameSite converts a SameSite attribute name back to an http.SameSite value
+func parseSameSite(name string) http.SameSite {
+	switch strings.ToLower(name) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
@@ -0,0 +1,83 @@
+package browser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// TestCookiesSurviveRetriesAndRestarts checks that a session cookie is sent back
+// on the next request and restored from disk in both file formats
+func TestCookiesSurviveRetriesAndRestarts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+			w.Write([]byte("<html><body>consent wall</body></html>"))
+			return
+		}
+		w.Write([]byte("<html><body>welcome back</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	if _, err := client.FetchPageWithRetry(server.URL, false); err != nil {
+		t.Fatalf("first fetch failed: %v", err)
+	}
+	content, err := client.FetchPageWithRetry(server.URL, false)
+	if err != nil {
+		t.Fatalf("second fetch failed: %v", err)
+	}
+	if content != "<html><body>welcome back</body></html>" {
+		t.Fatalf("session cookie was not sent back, got %q", content)
+	}
+
+	for _, name := range []string{"cookies.json", "cookies.txt"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := client.SaveCookies(path); err != nil {
+			t.Fatalf("saving %s failed: %v", name, err)
+		}
+
+		restored := NewClient()
+		if err := restored.LoadCookies(path); err != nil {
+			t.Fatalf("loading %s failed: %v", name, err)
+		}
+		all := restored.CookieJar().All()
+		if len(all) != 1 || all[0].Name != "session" || all[0].Value != "abc123" || !all[0].HostOnly {
+			t.Fatalf("unexpected cookies restored from %s: %+v", name, all)
+		}
+
+		content, err := restored.FetchPageWithRetry(server.URL, false)
+		if err != nil {
+			t.Fatalf("fetch with restored cookies failed: %v", err)
+		}
+		if content != "<html><body>welcome back</body></html>" {
+			t.Fatalf("restored cookie from %s was not sent, got %q", name, content)
+		}
+	}
+
+	client.ClearCookies()
+	if len(client.CookieJar().All()) != 0 {
+		t.Fatal("expected an empty jar after ClearCookies")
+	}
+}
+
+// TestCookiesForPublicSuffixes checks that cookies the jar refuses for a public
+// suffix are not saved either
+func TestCookiesForPublicSuffixes(t *testing.T) {
+	jar := NewCookieJar()
+	u, _ := url.Parse("https://www.example.co.uk/account")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "suffix", Value: "1", Domain: "co.uk"},
+		{Name: "tld", Value: "1", Domain: ".uk"},
+		{Name: "site", Value: "1", Domain: "example.co.uk"},
+	})
+	all := jar.All()
+	if len(all) != 1 || all[0].Name != "site" || all[0].Domain != "example.co.uk" {
+		t.Fatalf("unexpected stored cookies: %+v", all)
+	}
+	if sent := jar.Cookies(u); len(sent) != 1 || sent[0].Name != "site" {
+		t.Fatalf("unexpected cookies from the jar: %v", sent)
+	}
+}
@@ -0,0 +1,480 @@
+package browser
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadProgress is called while a file is saved with the bytes written so far
+// and the expected total, which is -1 when the server did not announce it
+type DownloadProgress func(name string, written, total int64)
+
+// Download describes a completed download, as recorded in the downloads list
+type Download struct {
+	URL         string    `json:"url"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	SHA256      string    `json:"sha256"`
+	Checksum    string    `json:"checksum,omitempty"` // Checksum published by the page, as "algorithm:hex"
+	Verified    bool      `json:"verified"`           // The published checksum matched
+	Resumed     bool      `json:"resumed,omitempty"`  // The download continued a partial file
+	Completed   time.Time `json:"completed"`
+}
+
+// DownloadOptions controls a download started with Client.Download
+type DownloadOptions struct {
+	Dir      string // Directory the file is saved in, created if missing
+	Checksum string // Expected checksum as "sha256:<hex>" or bare hex; empty skips verification
+}
+
+// ChecksumMismatchError is returned when a downloaded file does not match its published checksum
+type ChecksumMismatchError struct {
+	URL       string
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch for %s: expected %s, got %s", e.Algorithm, e.URL, e.Expected, e.Actual)
+}
+
+// Hash algorithms recognized in checksums, by name and by hex length
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+var checksumLengths = map[int]string{32: "md5", 40: "sha1", 64: "sha256", 128: "sha512"}
+
+// ParseChecksum splits "algorithm:hex" into its parts; for bare hex the
+// algorithm is inferred from the length
+func ParseChecksum(value string) (algorithm, digest string, err error) {
+	value = strings.TrimSpace(value)
+	if name, rest, found := strings.Cut(value, ":"); found {
+		algorithm = strings.ReplaceAll(strings.ToLower(name), "-", "")
+		digest = strings.ToLower(strings.TrimSpace(rest))
+	} else {
+		digest = strings.ToLower(value)
+		algorithm = checksumLengths[len(digest)]
+	}
+	if _, ok := checksumAlgorithms[algorithm]; !ok {
+		return "", "", fmt.Errorf("unsupported checksum %q", value)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || checksumLengths[len(digest)] != algorithm {
+		return "", "", fmt.Errorf("invalid %s checksum %q", algorithm, digest)
+	}
+	return algorithm, digest, nil
+}
+
+// checksumPattern matches a hex digest, optionally labeled with its algorithm
+var checksumPattern = regexp.MustCompile(`(?i)(?:\b(md5|sha-?1|sha-?256|sha-?512)\b[\s:=()]*)?\b([0-9a-f]{128}|[0-9a-f]{64}|[0-9a-f]{40}|[0-9a-f]{32})\b`)
+
+// FindChecksum looks for a checksum published next to fileName on a page, such
+// as a sha256sum line or a table cell, and returns it as "algorithm:hex"
+func FindChecksum(content, fileName string) string {
+	if fileName == "" {
+		return ""
+	}
+	const window = 400
+	best, bestDistance := "", -1
+	for offset := 0; ; {
+		index := strings.Index(content[offset:], fileName)
+		if index < 0 {
+			break
+		}
+		start := offset + index
+		end := start + len(fileName)
+		offset = end
+
+		from := max(0, start-window)
+		to := min(len(content), end+window)
+		for _, match := range checksumPattern.FindAllStringSubmatchIndex(content[from:to], -1) {
+			matchStart, matchEnd := from+match[4], from+match[5]
+			if matchStart < end && matchEnd > start {
+				continue // Part of the file name itself
+			}
+			distance := start - matchEnd
+			if matchStart >= end {
+				distance = matchStart - end
+			}
+			if bestDistance >= 0 && distance >= bestDistance {
+				continue
+			}
+			digest := strings.ToLower(content[matchStart:matchEnd])
+			algorithm := checksumLengths[len(digest)]
+			if match[2] >= 0 {
+				algorithm = strings.ReplaceAll(strings.ToLower(content[from+match[2]:from+match[3]]), "-", "")
+			}
+			if checksumLengths[len(digest)] != algorithm {
+				continue
+			}
+			best, bestDistance = algorithm+":"+digest, distance
+		}
+	}
+	return best
+}
+
+// VerifyDownload computes the SHA-256 of the downloaded file and, when a
+// checksum is given, compares it with the file
+func VerifyDownload(d *Download, checksum string) error {
+	hashes := map[string]hash.Hash{"sha256": sha256.New()}
+	algorithm, digest := "", ""
+	if checksum != "" {
+		var err error
+		if algorithm, digest, err = ParseChecksum(checksum); err != nil {
+			return err
+		}
+		if algorithm != "sha256" {
+			hashes[algorithm] = checksumAlgorithms[algorithm]()
+		}
+	}
+
+	file, err := os.Open(d.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open download: %v", err)
+	}
+	defer file.Close()
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return fmt.Errorf("failed to read download: %v", err)
+	}
+
+	d.SHA256 = hex.EncodeToString(hashes["sha256"].Sum(nil))
+	if checksum == "" {
+		return nil
+	}
+	d.Checksum = algorithm + ":" + digest
+	if actual := hex.EncodeToString(hashes[algorithm].Sum(nil)); actual != digest {
+		return &ChecksumMismatchError{URL: d.URL, Algorithm: algorithm, Expected: digest, Actual: actual}
+	}
+	d.Verified = true
+	return nil
+}
+
+// progressWriter reports the progress of a download at most every 100ms and once at the end
+type progressWriter struct {
+	w        io.Writer
+	name     string
+	written  int64
+	total    int64
+	report   DownloadProgress
+	reported time.Time
+}
+
+// Write passes p on and reports progress
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	if pw.report != nil && time.Since(pw.reported) >= 100*time.Millisecond {
+		pw.reported = time.Now()
+		pw.report(pw.name, pw.written, pw.total)
+	}
+	return n, err
+}
+
+// finish reports the final size
+func (pw *progressWriter) finish() {
+	if pw.report != nil {
+		pw.report(pw.name, pw.written, pw.total)
+	}
+}
+
+// SetDownloadProgress sets a function that is called while files are saved to disk
+func (c *Client) SetDownloadProgress(progress DownloadProgress) {
+	c.downloadProgress = progress
+}
+
+// Download saves rawURL into opts.Dir. Data is written to a ".part" file named
+// after a hash of the URL first; if one is left over from an interrupted
+// download, the rest is requested with a Range request that only applies while
+// the file's ETag or Last-Modified is unchanged. The completed file gets its
+// name from Content-Disposition or the URL and is checked against opts.Checksum.
+func (c *Client) Download(ctx context.Context, rawURL string, opts DownloadOptions) (*Download, error) {
+	if opts.Checksum != "" {
+		if _, _, err := ParseChecksum(opts.Checksum); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := url.Parse(rawURL); err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %v", rawURL, err)
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %v", err)
+	}
+	partPath := partPathFor(opts.Dir, rawURL)
+	validatorPath := partPath + ".json"
+	// Without a validator there is no telling whether the partial file is
+	// still the start of what the server has now, so it is fetched again
+	var offset int64
+	validator := loadPartValidator(validatorPath)
+	if info, err := os.Stat(partPath); err == nil && validator.ifRange() != "" {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Accept-Encoding", "identity") // Byte ranges refer to the file as stored
+	req.Header.Set("Cache-Control", "no-store")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator.ifRange())
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, classifyError(ctx, rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	finalURL := resp.Request.URL.String()
+	complete := false
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if start := contentRangeStart(resp); offset == 0 || start != offset {
+			return nil, fmt.Errorf("failed to resume %s: server sent bytes from %d instead of %d", finalURL, start, offset)
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 && contentRangeTotal(resp) == offset:
+		complete = true
+	case resp.StatusCode >= 400:
+		return nil, &HTTPStatusError{
+			URL:        finalURL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	default:
+		offset = 0
+		if err := savePartValidator(validatorPath, resp.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &Download{
+		URL:         finalURL,
+		ContentType: resp.Header.Get("Content-Type"),
+		Resumed:     offset > 0,
+	}
+	if !complete {
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+		if c.maxDownloadSize > 0 && total > c.maxDownloadSize {
+			return nil, &TooLargeError{URL: finalURL, Limit: c.maxDownloadSize}
+		}
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if offset > 0 {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(partPath, flags, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create download file: %v", err)
+		}
+		limit := int64(0)
+		if c.maxDownloadSize > 0 {
+			limit = c.maxDownloadSize - offset
+		}
+
+		// An interrupted download keeps its partial file so it can be resumed
+		progress := &progressWriter{w: file, name: fileNameFor(resp.Header, resp.Request.URL), written: offset, total: total, report: c.downloadProgress}
+		_, err = CopyLimited(progress, resp.Body, limit, finalURL)
+		progress.finish()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		var tooLarge *TooLargeError
+		if errors.As(err, &tooLarge) {
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+		if err != nil {
+			return nil, classifyError(ctx, rawURL, err)
+		}
+	}
+
+	d.Path = partPath
+	if err := VerifyDownload(d, opts.Checksum); err != nil {
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+		return nil, err
+	}
+	info, err := os.Stat(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save download: %v", err)
+	}
+	d.Size = info.Size()
+
+	// Move the completed file to its final, unused name
+	target, err := createUnique(opts.Dir, fileNameFor(resp.Header, resp.Request.URL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download file: %v", err)
+	}
+	target.Close()
+	if err := os.Rename(partPath, target.Name()); err != nil {
+		os.Remove(target.Name())
+		return nil, fmt.Errorf("failed to save download: %v", err)
+	}
+	os.Remove(validatorPath)
+	d.Path = target.Name()
+	d.Completed = time.Now()
+	return d, nil
+}
+
+// partPathFor returns the ".part" file a download of rawURL is written to, so
+// that different URLs serving files of the same name never share one
+func partPathFor(dir, rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".part")
+}
+
+// partValidator identifies the version of a file a ".part" file belongs to
+type partValidator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ifRange returns the If-Range value for resuming: a strong ETag, or else
+// Last-Modified; weak ETags cannot be used for ranges
+func (v partValidator) ifRange() string {
+	if v.ETag != "" && !strings.HasPrefix(v.ETag, "W/") {
+		return v.ETag
+	}
+	return v.LastModified
+}
+
+// loadPartValidator reads the validator stored next to a ".part" file; a
+// missing or unreadable file is an empty validator
+func loadPartValidator(path string) partValidator {
+	var v partValidator
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &v)
+	}
+	return v
+}
+
+// savePartValidator stores the ETag and Last-Modified of a response next to
+// the ".part" file it is written to
+func savePartValidator(path string, header http.Header) error {
+	v := partValidator{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+	if v.ifRange() == "" {
+		os.Remove(path)
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode download validator: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write download validator: %v", err)
+	}
+	return nil
+}
+
+// contentRangeStart returns the first byte of a "bytes start-end/total" Content-Range, or -1
+func contentRangeStart(resp *http.Response) int64 {
+	value := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	start, _, found := strings.Cut(value, "-")
+	if !found {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// contentRangeTotal returns the total size of a Content-Range such as "bytes */1234", or -1
+func contentRangeTotal(resp *http.Response) int64 {
+	_, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if !found {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// DefaultDownloadDir returns ~/Downloads
+func DefaultDownloadDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %v", err)
+	}
+	return filepath.Join(home, "Downloads"), nil
+}
+
+// DefaultDownloadsListPath returns ~/.brauser/downloads.json
+func DefaultDownloadsListPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %v", err)
+	}
+	return filepath.Join(home, ".brauser", "downloads.json"), nil
+}
+
+// LoadDownloads reads the downloads list; a missing file is an empty list
+func LoadDownloads(path string) ([]Download, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloads list: %v", err)
+	}
+	var downloads []Download
+	if err := json.Unmarshal(data, &downloads); err != nil {
+		return nil, fmt.Errorf("failed to parse downloads list: %v", err)
+	}
+	return downloads, nil
+}
+
+// RecordDownload appends a completed download to the downloads list
+func RecordDownload(path string, d *Download) error {
+	downloads, err := LoadDownloads(path)
+	if err != nil {
+		return err
+	}
+	downloads = append(downloads, *d)
+	data, err := json.MarshalIndent(downloads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode downloads list: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create downloads list directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write downloads list: %v", err)
+	}
+	return nil
+}
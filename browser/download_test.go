@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDownloadResumeAndChecksum checks that a partial download is resumed with
+// a Range request and verified against a checksum found on the linking page
+func TestDownloadResumeAndChecksum(t *testing.T) {
+	data := bytes.Repeat([]byte("brauser release artifact "), 1000)
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	page := `<table><tr><td><a href="/tool.tar.gz">tool.tar.gz</a></td><td>12 MB</td>` +
+		`<td><code>` + digest + `</code></td></tr><tr><td>other.zip</td><td>` + strings.Repeat("0", 64) + `</td></tr></table>`
+	checksum := FindChecksum(page, "tool.tar.gz")
+	if checksum != "sha256:"+digest {
+		t.Fatalf("FindChecksum = %q", checksum)
+	}
+
+	dir := t.TempDir()
+	writePart := func(etag string) {
+		part := partPathFor(dir, server.URL+"/tool.tar.gz")
+		if err := os.WriteFile(part, data[:1000], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(part+".json", []byte(`{"etag":"\"`+etag+`\""}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePart("v2")
+	client := NewClient()
+	d, err := client.Download(context.Background(), server.URL+"/tool.tar.gz", DownloadOptions{Dir: dir, Checksum: checksum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Resumed || !d.Verified || d.Size != int64(len(data)) || d.Path != filepath.Join(dir, "tool.tar.gz") {
+		t.Fatalf("unexpected download: %+v", d)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Fatalf("expected one Range request, got %q", ranges)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.part*")); len(entries) != 0 {
+		t.Fatalf("partial files were left behind: %q", entries)
+	}
+
+	// A partial file of an older version is replaced by the whole new file
+	writePart("v1")
+	stale, err := client.Download(context.Background(), server.URL+"/tool.tar.gz", DownloadOptions{Dir: dir, Checksum: checksum})
+	if err != nil || stale.Resumed || stale.Size != int64(len(data)) {
+		t.Fatalf("expected a full download, got %+v, %v", stale, err)
+	}
+	os.Remove(stale.Path)
+
+	var mismatch *ChecksumMismatchError
+	_, err = client.Download(context.Background(), server.URL+"/tool.tar.gz", DownloadOptions{Dir: dir, Checksum: strings.Repeat("0", 64)})
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tool (1).tar.gz")); !os.IsNotExist(err) {
+		t.Fatalf("mismatching download was kept")
+	}
+
+	list := filepath.Join(dir, "downloads.json")
+	if err := RecordDownload(list, d); err != nil {
+		t.Fatal(err)
+	}
+	if downloads, err := LoadDownloads(list); err != nil || len(downloads) != 1 || downloads[0].SHA256 != digest {
+		t.Fatalf("LoadDownloads = %+v, %v", downloads, err)
+	}
+}
+
+// TestDownloadResumeAtWrongOffset checks that a 206 response that does not
+// continue where the partial file ends is an error
+func TestDownloadResumeAtWrongOffset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-9/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	part := partPathFor(dir, server.URL+"/file.bin")
+	os.WriteFile(part, []byte("01234"), 0644)
+	os.WriteFile(part+".json", []byte(`{"last_modified":"Mon, 02 Jan 2006 15:04:05 GMT"}`), 0644)
+	if _, err := NewClient().Download(context.Background(), server.URL+"/file.bin", DownloadOptions{Dir: dir}); err == nil {
+		t.Fatal("expected an error for a range that does not match the partial file")
+	}
+	if data, _ := os.ReadFile(part); string(data) != "01234" {
+		t.Fatalf("partial file changed to %q", data)
+	}
+}
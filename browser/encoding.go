@@ -0,0 +1,121 @@
+package browser
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding lists exactly the content codings decodeContentEncoding understands
+const acceptEncoding = "gzip, deflate, br"
+
+// ContentEncodingError is returned when a response uses a content coding the client cannot decode
+type ContentEncodingError struct {
+	Encoding string
+}
+
+func (e *ContentEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q", e.Encoding)
+}
+
+// decodedBody is a response body with all content codings removed
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes every decoder layer
+func (b *decodedBody) Close() error {
+	var firstErr error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if err := b.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// decodeContentEncoding wraps body in a decoder for each coding in a
+// Content-Encoding header. Codings are listed in the order they were applied,
+// so they are removed from last to first. An empty body stays empty.
+func decodeContentEncoding(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	// 204 and 304 responses and answers to HEAD requests keep the header
+	// without any encoded data, which the decoders would reject
+	buffered := bufio.NewReader(body)
+	_, err := buffered.Peek(1)
+	empty := err == io.EOF
+	decoded := &decodedBody{Reader: buffered}
+
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		switch coding {
+		case "", "identity":
+			continue
+
+
+		case "gzip", "x-gzip":
+			if empty {
+				continue
+			}
+			gzipReader, err := gzip.NewReader(decoded.Reader)
+			if err != nil {
+				decoded.Close()
+				return nil, fmt.Errorf("invalid gzip content: %v", err)
+			}
+			decoded.Reader = gzipReader
+			decoded.closers = append(decoded.closers, gzipReader)
+
+		case "deflate":
+			if empty {
+				continue
+			}
+			deflateReader, err := newDeflateReader(decoded.Reader)
+			if err != nil {
+				decoded.Close()
+				return nil, fmt.Errorf("invalid deflate content: %v", err)
+			}
+			decoded.Reader = deflateReader
+			decoded.closers = append(decoded.closers, deflateReader)
+
+		case "br":
+			if empty {
+				continue
+			}
+			decoded.Reader = brotli.NewReader(decoded.Reader)
+
+		default:
+			decoded.Close()
+			return nil, &ContentEncodingError{Encoding: coding}
+		}
+	}
+
+	return decoded, nil
+}
+
+// newDeflateReader decodes "deflate" content. RFC 9110 defines it as zlib-wrapped
+// data, but many servers send a raw deflate stream, so the zlib header is sniffed.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(header) == 2 && isZlibHeader(header[0], header[1]) {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// isZlibHeader checks the CMF and FLG bytes of a zlib stream (RFC 1950)
+func isZlibHeader(cmf, flg byte) bool {
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
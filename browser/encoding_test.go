@@ -0,0 +1,84 @@
+package browser
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestDecodeContentEncoding checks every supported coding, stacked codings and unknown ones
+func TestDecodeContentEncoding(t *testing.T) {
+	const page = "<html><body>compressed page</body></html>"
+
+	compress := func(coding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch coding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "zlib":
+			w = zlib.NewWriter(&buf)
+		case "raw":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		}
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+	}{
+		{"identity", "", []byte(page)},
+		{"gzip", "gzip", compress("gzip", []byte(page))},
+		{"zlib deflate", "deflate", compress("zlib", []byte(page))},
+		{"raw deflate", "deflate", compress("raw", []byte(page))},
+		{"brotli", "br", compress("br", []byte(page))},
+		{"stacked", "gzip, br", compress("br", compress("gzip", []byte(page)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := decodeContentEncoding(bytes.NewReader(tt.body), tt.header)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer reader.Close()
+
+			decoded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if string(decoded) != page {
+				t.Errorf("decoded = %q, want %q", decoded, page)
+			}
+		})
+	}
+
+	for _, header := range []string{"gzip", "deflate", "br", "gzip, br"} {
+		reader, err := decodeContentEncoding(strings.NewReader(""), header)
+		if err != nil {
+			t.Fatalf("empty %s body: %v", header, err)
+		}
+		if decoded, err := io.ReadAll(reader); err != nil || len(decoded) != 0 {
+			t.Errorf("empty %s body decoded to %q, %v", header, decoded, err)
+		}
+	}
+
+	_, err := decodeContentEncoding(strings.NewReader(page), "zstd")
+	var encodingErr *ContentEncodingError
+	if !errors.As(err, &encodingErr) || encodingErr.Encoding != "zstd" {
+		t.Errorf("expected ContentEncodingError for zstd, got %v", err)
+	}
+}
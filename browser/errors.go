@@ -0,0 +1,188 @@
+package browser
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPStatusError is returned when a server answers with a 4xx or 5xx status
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // Delay requested by a Retry-After header, 0 if absent
+	Page       *PageResult   // The error page as returned by the server
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.URL, e.Status)
+}
+
+// TimeoutError is returned when a request or the response body takes too long
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// DNSError is returned when the host name of a URL cannot be resolved
+type DNSError struct {
+	URL      string
+	Host     string
+	NotFound bool // The name does not exist, as opposed to a failing resolver
+	Err      error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("DNS lookup for %s failed: %v", e.Host, e.Err)
+}
+
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// TLSError is returned when the TLS handshake or certificate verification fails
+type TLSError struct {
+	URL string
+	Err error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS error for %s: %v", e.URL, e.Err)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// TooLargeError is returned when a response body exceeds the configured size limit
+type TooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("response from %s exceeds the %d byte limit", e.URL, e.Limit)
+}
+
+// RobotsDisallowedError is returned when robots.txt disallows a URL and the
+// client runs in RobotsEnforce mode
+type RobotsDisallowedError struct {
+	URL       string
+	UserAgent string
+}
+
+func (e *RobotsDisallowedError) Error() string {
+	return fmt.Sprintf("robots.txt disallows fetching %s", e.URL)
+}
+
+// IsRetryable reports whether a failed fetch may succeed when tried again.
+// Rate limiting, temporary server errors, timeouts and resolver failures are
+// retryable; missing pages, certificate problems, oversized bodies and robots.txt
+// refusals are not.
+func IsRetryable(err error) bool {
+	var statusErr *HTTPStatusError
+	var timeoutErr *TimeoutError
+	var dnsErr *DNSError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &statusErr):
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	case errors.As(err, &timeoutErr):
+		return true
+	case errors.As(err, &dnsErr):
+		return !dnsErr.NotFound
+	default:
+		return false
+	}
+}
+
+// classifyError wraps a transport error from http.Client.Do in one of the typed
+// errors. When the caller's own context is done its error is returned as is, so
+// the request is not retried after the caller gave up.
+func classifyError(ctx context.Context, url string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &DNSError{URL: url, Host: dnsErr.Name, NotFound: dnsErr.IsNotFound, Err: err}
+	}
+
+	if isTLSError(err) {
+		return &TLSError{URL: url, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &TimeoutError{URL: url, Err: err}
+	}
+
+	return err
+}
+
+// isTLSError checks for handshake and certificate verification failures
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,704 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Form encodings
+const (
+	FormURLEncoded = "application/x-www-form-urlencoded"
+	FormMultipart  = "multipart/form-data"
+	FormTextPlain  = "text/plain"
+)
+
+// Form is an HTML form with its fields in document order
+type Form struct {
+	Number     int
+	Name       string // id or name attribute, if any
+	Action     string // Absolute URL the form is submitted to
+	Method     string // GET or POST
+	Enctype    string // FormURLEncoded, FormMultipart or FormTextPlain
+	NoValidate bool   // Required fields may be left empty
+	Fields     []*FormField
+	page       string // URL of the page the form is on
+}
+
+// FormField is one control of a form. Radio buttons with the same name form a
+// single field with one option per button.
+type FormField struct {
+	Number      int
+	Name        string
+	Type        string // Input type, "select", "textarea", "radio" or "submit"
+	Label       string
+	Value       string // Current value; for checkboxes the value sent when checked
+	Placeholder string
+	Checked     bool
+	Required    bool
+	Disabled    bool
+	Multiple    bool
+	Options     []FormOption // Choices of select and radio fields
+	FormAction  string       // Submit buttons may override the form's action,
+	FormMethod  string       // method
+	FormEnctype string       // and encoding
+}
+
+// FormOption is one choice of a select or radio field
+type FormOption struct {
+	Value    string
+	Label    string
+	Selected bool
+	Disabled bool
+}
+
+// FormSubmission is an encoded form, ready to be fetched
+type FormSubmission struct {
+	Method      string
+	URL         string
+	ContentType string // Empty for GET
+	Body        []byte
+}
+
+// FetchOptions returns the options to load the submission with
+func (s *FormSubmission) FetchOptions(referer string) FetchOptions {
+	if s.Method == http.MethodGet {
+		return FetchOptions{Referer: referer}
+	}
+	return FetchOptions{Method: s.Method, Body: s.Body, ContentType: s.ContentType, Referer: referer}
+}
+
+// ParseForms returns the forms of a document. Controls outside a form that
+// name it with the form attribute are included.
+func ParseForms(doc *goquery.Document, baseURL string) []*Form {
+	base, _ := url.Parse(baseURL)
+	var forms []*Form
+	byNode := map[*html.Node]*Form{}
+	byID := map[string]*Form{}
+	doc.Find("form").Each(func(i int, s *goquery.Selection) {
+		form := &Form{
+			Number:     len(forms) + 1,
+			Name:       firstAttr(s, "id", "name"),
+			Action:     resolveAgainst(base, strings.TrimSpace(s.AttrOr("action", ""))),
+			Method:     formMethod(s.AttrOr("method", "")),
+			Enctype:    formEnctype(s.AttrOr("enctype", "")),
+			NoValidate: hasAttr(s, "novalidate"),
+			page:       baseURL,
+		}
+		forms = append(forms, form)
+		byNode[s.Nodes[0]] = form
+		if id := s.AttrOr("id", ""); id != "" {
+			byID[id] = form
+		}
+	})
+
+	radios := map[*Form]map[string]*FormField{}
+	doc.Find("input, select, textarea, button").Each(func(i int, s *goquery.Selection) {
+		var form *Form
+		if id, ok := s.Attr("form"); ok {
+			form = byID[id]
+		} else if owner := s.Closest("form"); owner.Length() > 0 {
+			form = byNode[owner.Nodes[0]]
+		}
+		if form == nil {
+			return
+		}
+
+		field := parseField(doc, s, base)
+		if field == nil {
+			return
+		}
+		if field.Type == "radio" {
+			if radios[form] == nil {
+				radios[form] = map[string]*FormField{}
+			}
+			if group := radios[form][field.Name]; group != nil && field.Name != "" {
+				group.Options = append(group.Options, field.Options...)
+				group.Required = group.Required || field.Required
+				return
+			}
+			radios[form][field.Name] = field
+			field.Label = field.Name
+		}
+		field.Number = len(form.Fields) + 1
+		form.Fields = append(form.Fields, field)
+	})
+	return forms
+}
+
+// parseField describes a form control, or returns nil for controls that are
+// never submitted such as reset buttons
+func parseField(doc *goquery.Document, s *goquery.Selection, base *url.URL) *FormField {
+	field := &FormField{
+		Name:        s.AttrOr("name", ""),
+		Label:       fieldLabel(doc, s),
+		Placeholder: s.AttrOr("placeholder", ""),
+		Required:    hasAttr(s, "required"),
+		Disabled:    hasAttr(s, "disabled") || s.ParentsFiltered("fieldset[disabled]").Length() > 0,
+	}
+
+	switch goquery.NodeName(s) {
+	case "select":
+		field.Type = "select"
+		field.Multiple = hasAttr(s, "multiple")
+		selected := false
+		s.Find("option").Each(func(i int, option *goquery.Selection) {
+			label := collapseSpace(option.Text())
+			value, ok := option.Attr("value")
+			if !ok {
+				value = label
+			}
+			isSelected := hasAttr(option, "selected") && (field.Multiple || !selected)
+			selected = selected || isSelected
+			field.Options = append(field.Options, FormOption{Value: value, Label: label, Selected: isSelected, Disabled: hasAttr(option, "disabled")})
+		})
+		// A single select shows its first choice when none is marked as selected
+		if !field.Multiple && !selected {
+			for i := range field.Options {
+				if !field.Options[i].Disabled {
+					field.Options[i].Selected = true
+					break
+				}
+			}
+		}
+		return field
+
+	case "textarea":
+		field.Type = "textarea"
+		field.Value = s.Text()
+		return field
+
+	case "button":
+		kind := strings.ToLower(s.AttrOr("type", "submit"))
+		if kind != "submit" {
+			return nil
+		}
+		field.Type = "submit"
+		field.Value = s.AttrOr("value", "")
+		if field.Label == "" {
+			field.Label = collapseSpace(s.Text())
+		}
+		field.setOverrides(s, base)
+		return field
+	}
+
+	field.Type = strings.ToLower(s.AttrOr("type", "text"))
+	field.Value = s.AttrOr("value", "")
+	switch field.Type {
+	case "reset", "button":
+		return nil
+	case "checkbox", "radio":
+		if _, ok := s.Attr("value"); !ok {
+			field.Value = "on"
+		}
+		field.Checked = hasAttr(s, "checked")
+		if field.Type == "radio" {
+			field.Options = []FormOption{{Value: field.Value, Label: field.Label, Selected: field.Checked, Disabled: field.Disabled}}
+			field.Disabled = false
+		}
+	case "submit", "image":
+		if field.Label == "" {
+			field.Label = firstAttr(s, "value", "alt")
+		}
+		if field.Label == "" {
+			field.Label = "Submit"
+		}
+		field.setOverrides(s, base)
+	case "file":
+		// Like browsers, ignore the value a page gives a file field; only
+		// paths the user chose with Set are uploaded
+		field.Value = ""
+	}
+	return field
+}
+
+// setOverrides reads the formaction, formmethod and formenctype of a submit button
+func (f *FormField) setOverrides(s *goquery.Selection, base *url.URL) {
+	if action, ok := s.Attr("formaction"); ok {
+		f.FormAction = resolveAgainst(base, strings.TrimSpace(action))
+	}
+	if method, ok := s.Attr("formmethod"); ok {
+		f.FormMethod = formMethod(method)
+	}
+	if enctype, ok := s.Attr("formenctype"); ok {
+		f.FormEnctype = formEnctype(enctype)
+	}
+}
+
+// fieldLabel finds the text describing a control: its label element,
+// aria-label, title or placeholder
+func fieldLabel(doc *goquery.Document, s *goquery.Selection) string {
+	if id := s.AttrOr("id", ""); id != "" {
+		var label string
+		doc.Find("label").EachWithBreak(func(i int, l *goquery.Selection) bool {
+			if l.AttrOr("for", "") == id {
+				label = collapseSpace(l.Text())
+				return false
+			}
+			return true
+		})
+		if label != "" {
+			return label
+		}
+	}
+	if wrapper := s.Closest("label"); wrapper.Length() > 0 {
+		text := wrapper.Clone()
+		text.Find("select, textarea, option").Remove()
+		if label := collapseSpace(text.Text()); label != "" {
+			return label
+		}
+	}
+	return firstAttr(s, "aria-label", "title", "placeholder")
+}
+
+// Field returns the field with the given number, or nil
+func (f *Form) Field(number int) *FormField {
+	if number < 1 || number > len(f.Fields) {
+		return nil
+	}
+	return f.Fields[number-1]
+}
+
+// FieldByName returns the first field with the given name, or nil
+func (f *Form) FieldByName(name string) *FormField {
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// IsButton reports whether the field submits the form
+func (f *FormField) IsButton() bool {
+	return f.Type == "submit" || f.Type == "image"
+}
+
+// Display returns the current value of the field as shown to the user.
+// Passwords are masked.
+func (f *FormField) Display() string {
+	switch f.Type {
+	case "checkbox":
+		if f.Checked {
+			return "[x]"
+		}
+		return "[ ]"
+	case "select", "radio":
+		var labels []string
+		for _, option := range f.Options {
+			if option.Selected {
+				labels = append(labels, option.Label)
+			}
+		}
+		return strings.Join(labels, ", ")
+	case "password":
+		return strings.Repeat("*", len([]rune(f.Value)))
+	}
+	return f.Value
+}
+
+// Set changes the value of a field. Checkboxes take yes/no, select and radio
+// fields an option number, value or label (comma-separated for multiple
+// selects) and file fields the path of a local file.
+func (f *FormField) Set(value string) error {
+	if f.Disabled {
+		return fmt.Errorf("field %s is disabled", f.describe())
+	}
+	switch f.Type {
+	case "submit", "image":
+		return fmt.Errorf("field %s is a button; submit the form with it instead", f.describe())
+	case "checkbox":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "yes", "y", "true", "1", "x", "checked":
+			f.Checked = true
+		case "off", "no", "n", "false", "0", "", "unchecked":
+			f.Checked = false
+		default:
+			return fmt.Errorf("checkbox %s takes yes or no, not %q", f.describe(), value)
+		}
+		return nil
+	case "select", "radio":
+		choices := []string{value}
+		if f.Multiple {
+			choices = strings.Split(value, ",")
+		}
+		selected := make([]bool, len(f.Options))
+		for _, choice := range choices {
+			i := f.option(strings.TrimSpace(choice))
+			if i < 0 {
+				return fmt.Errorf("%s has no option %q", f.describe(), strings.TrimSpace(choice))
+			}
+			if f.Options[i].Disabled {
+				return fmt.Errorf("option %q of %s is disabled", f.Options[i].Label, f.describe())
+			}
+			selected[i] = true
+		}
+		for i := range f.Options {
+			f.Options[i].Selected = selected[i]
+		}
+		return nil
+	case "file":
+		if value != "" {
+			if info, err := os.Stat(value); err != nil || info.IsDir() {
+				return fmt.Errorf("cannot attach %s: not a readable file", value)
+			}
+		}
+	}
+	f.Value = value
+	return nil
+}
+
+// option finds an option by number, value or label
+func (f *FormField) option(choice string) int {
+	if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(f.Options) {
+		if f.Options[n-1].Value == choice || !f.hasOptionValue(choice) {
+			return n - 1
+		}
+	}
+	for i, option := range f.Options {
+		if option.Value == choice {
+			return i
+		}
+	}
+	for i, option := range f.Options {
+		if strings.EqualFold(option.Label, choice) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasOptionValue reports whether one of the options has exactly this value
+func (f *FormField) hasOptionValue(value string) bool {
+	for _, option := range f.Options {
+		if option.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// describe names the field in error messages
+func (f *FormField) describe() string {
+	if f.Label != "" {
+		return fmt.Sprintf("%d (%s)", f.Number, f.Label)
+	}
+	if f.Name != "" {
+		return fmt.Sprintf("%d (%s)", f.Number, f.Name)
+	}
+	return strconv.Itoa(f.Number)
+}
+
+// Buttons returns the submit buttons of the form
+func (f *Form) Buttons() []*FormField {
+	var buttons []*FormField
+	for _, field := range f.Fields {
+		if field.IsButton() {
+			buttons = append(buttons, field)
+		}
+	}
+	return buttons
+}
+
+// formEntry is one name/value pair of a submission
+type formEntry struct {
+	name  string
+	value string
+	file  bool // value is the path of a file to upload, or empty for none
+}
+
+// Submit encodes the form as submitted with the given button field number, or
+// with the first submit button when button is 0
+func (f *Form) Submit(button int) (*FormSubmission, error) {
+	var submitter *FormField
+	if button != 0 {
+		submitter = f.Field(button)
+		if submitter == nil || !submitter.IsButton() {
+			return nil, fmt.Errorf("field %d of form %d is not a submit button", button, f.Number)
+		}
+	} else if buttons := f.Buttons(); len(buttons) > 0 {
+		submitter = buttons[0]
+	}
+
+	action, method, enctype := f.Action, f.Method, f.Enctype
+	if submitter != nil {
+		if submitter.Disabled {
+			return nil, fmt.Errorf("button %s is disabled", submitter.describe())
+		}
+		if submitter.FormAction != "" {
+			action = submitter.FormAction
+		}
+		if submitter.FormMethod != "" {
+			method = submitter.FormMethod
+		}
+		if submitter.FormEnctype != "" {
+			enctype = submitter.FormEnctype
+		}
+	}
+	if method != http.MethodGet && method != http.MethodPost {
+		return nil, fmt.Errorf("form %d uses method %q, which cannot be submitted", f.Number, strings.ToLower(method))
+	}
+	if err := CheckNavigation(f.page, action); err != nil {
+		return nil, err
+	}
+
+	entries, err := f.entries(submitter)
+	if err != nil {
+		return nil, err
+	}
+
+	if method == http.MethodGet {
+		target, err := url.Parse(action)
+		if err != nil {
+			return nil, fmt.Errorf("invalid form action %q: %v", action, err)
+		}
+		target.RawQuery = encodeURLEncoded(entries)
+		return &FormSubmission{Method: method, URL: target.String()}, nil
+	}
+
+	submission := &FormSubmission{Method: method, URL: action, ContentType: enctype}
+	switch enctype {
+	case FormMultipart:
+		submission.Body, submission.ContentType, err = encodeMultipart(entries)
+		if err != nil {
+			return nil, err
+		}
+	case FormTextPlain:
+		var body strings.Builder
+		for _, entry := range entries {
+			body.WriteString(entry.name + "=" + filepath.Base(entry.value) + "\r\n")
+		}
+		submission.Body = []byte(body.String())
+	default:
+		submission.Body = []byte(encodeURLEncoded(entries))
+	}
+	return submission, nil
+}
+
+// entries lists the name/value pairs a submission sends, in document order
+func (f *Form) entries(submitter *FormField) ([]formEntry, error) {
+	var entries []formEntry
+	for _, field := range f.Fields {
+		if field.Disabled {
+			continue
+		}
+		if !f.NoValidate && field.Required && field.empty() {
+			return nil, fmt.Errorf("field %s of form %d is required", field.describe(), f.Number)
+		}
+		if field.Name == "" && field.Type != "image" {
+			continue
+		}
+
+		switch field.Type {
+		case "submit":
+			if field == submitter {
+				entries = append(entries, formEntry{name: field.Name, value: field.Value})
+			}
+		case "image":
+			if field == submitter {
+				prefix := ""
+				if field.Name != "" {
+					prefix = field.Name + "."
+				}
+				entries = append(entries, formEntry{name: prefix + "x", value: "0"}, formEntry{name: prefix + "y", value: "0"})
+			}
+		case "checkbox":
+			if field.Checked {
+				entries = append(entries, formEntry{name: field.Name, value: field.Value})
+			}
+		case "select", "radio":
+			for _, option := range field.Options {
+				if option.Selected && !option.Disabled {
+					entries = append(entries, formEntry{name: field.Name, value: option.Value})
+				}
+			}
+		case "file":
+			entries = append(entries, formEntry{name: field.Name, value: field.Value, file: true})
+		default:
+			entries = append(entries, formEntry{name: field.Name, value: normalizeNewlines(field.Value)})
+		}
+	}
+	return entries, nil
+}
+
+// empty reports whether a required field still needs a value
+func (f *FormField) empty() bool {
+	switch f.Type {
+	case "checkbox":
+		return !f.Checked
+	case "select", "radio":
+		for _, option := range f.Options {
+			if option.Selected && option.Value != "" {
+				return false
+			}
+		}
+		return true
+	case "submit", "image", "hidden":
+		return false
+	}
+	return f.Value == ""
+}
+
+// encodeURLEncoded encodes entries as application/x-www-form-urlencoded,
+// keeping their order. Files are sent by name only.
+func encodeURLEncoded(entries []formEntry) string {
+	parts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		value := entry.value
+		if entry.file && value != "" {
+			value = filepath.Base(value)
+		}
+		parts = append(parts, url.QueryEscape(entry.name)+"="+url.QueryEscape(value))
+	}
+	return strings.Join(parts, "&")
+}
+
+// quoteEscaper escapes quoted Content-Disposition parameters like mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// encodeMultipart encodes entries as multipart/form-data, reading the
+// contents of attached files
+func encodeMultipart(entries []formEntry) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, entry := range entries {
+		if !entry.file {
+			if err := writer.WriteField(entry.name, entry.value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		var data []byte
+		fileName, contentType := "", "application/octet-stream"
+		if entry.value != "" {
+			var err error
+			if data, err = os.ReadFile(entry.value); err != nil {
+				return nil, "", fmt.Errorf("failed to read file for upload: %v", err)
+			}
+			fileName = filepath.Base(entry.value)
+			if byExtension := mime.TypeByExtension(filepath.Ext(fileName)); byExtension != "" {
+				contentType = byExtension
+			}
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(entry.name), quoteEscaper.Replace(fileName)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// formMethod normalizes a method attribute; anything unknown means GET
+func formMethod(method string) string {
+	switch strings.ToLower(strings.TrimSpace(method)) {
+	case "post":
+		return http.MethodPost
+	case "dialog":
+		return "DIALOG"
+	}
+	return http.MethodGet
+}
+
+// formEnctype normalizes an enctype attribute; anything unknown means urlencoded
+func formEnctype(enctype string) string {
+	switch strings.ToLower(strings.TrimSpace(enctype)) {
+	case FormMultipart:
+		return FormMultipart
+	case FormTextPlain:
+		return FormTextPlain
+	}
+	return FormURLEncoded
+}
+
+// resolveAgainst resolves a reference against the base URL; an empty
+// reference is the base itself
+func resolveAgainst(base *url.URL, ref string) string {
+	if base == nil {
+		return ref
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// originOf returns the scheme://host of a URL, or "" for URLs without one
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// refererFor returns the Referer to send from a page to target under the
+// strict-origin-when-cross-origin policy: the page URL within its origin, only
+// the origin across origins and nothing from https to http
+func refererFor(referer, target string) string {
+	from, err := url.Parse(referer)
+	if err != nil || from.Host == "" {
+		return ""
+	}
+	to, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	if strings.EqualFold(from.Scheme, "https") && !strings.EqualFold(to.Scheme, "https") {
+		return ""
+	}
+	if originOf(referer) != originOf(target) {
+		return originOf(referer) + "/"
+	}
+	page := *from
+	page.User, page.Fragment, page.RawFragment = nil, "", ""
+	return page.String()
+}
+
+// normalizeNewlines converts line breaks to CRLF, as forms send them
+func normalizeNewlines(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return strings.ReplaceAll(value, "\n", "\r\n")
+}
+
+// collapseSpace joins the words of a text with single spaces
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// firstAttr returns the first non-empty attribute of the given names
+func firstAttr(s *goquery.Selection, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(s.AttrOr(name, "")); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether the element has the attribute, whatever its value
+func hasAttr(s *goquery.Selection, name string) bool {
+	_, ok := s.Attr(name)
+	return ok
+}
@@ -0,0 +1,198 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const formPage = `<html><body>
+<form id="search" action="/search">
+  <label for="q">Search</label> <input id="q" name="q" required>
+  <input type="hidden" name="lang" value="en">
+  <select name="sort"><option value="new">Newest</option><option value="top" selected>Top</option></select>
+  <label><input type="checkbox" name="safe" checked> Safe search</label>
+  <input type="radio" name="size" value="s"> <input type="radio" name="size" value="m" checked>
+  <button>Go</button>
+  <button name="lucky" value="1" formmethod="post" formaction="/lucky">Lucky</button>
+  <input type="reset">
+</form>
+<textarea name="note" form="search">line one
+line two</textarea>
+<form action="/upload" method="post" enctype="multipart/form-data">
+  <input name="title" value="report"> <input type="file" name="attachment"> <input type="submit" value="Upload">
+</form>
+</body></html>`
+
+// TestFormSubmission checks that forms are parsed with numbered fields and
+// submitted with the right method and encoding
+func TestFormSubmission(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(formPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := ParseForms(doc, "https://example.com/start")
+	if len(forms) != 2 {
+		t.Fatalf("expected 2 forms, got %d", len(forms))
+	}
+	search := forms[0]
+	var names []string
+	for _, field := range search.Fields {
+		names = append(names, field.Type+":"+field.Name)
+	}
+	if got := strings.Join(names, " "); got != "text:q hidden:lang select:sort checkbox:safe radio:size submit: submit:lucky textarea:note" {
+		t.Fatalf("unexpected fields: %s", got)
+	}
+	if search.Field(1).Label != "Search" || search.Field(4).Label != "Safe search" || search.Field(3).Display() != "Top" {
+		t.Fatalf("unexpected labels or values: %+v", search.Fields)
+	}
+
+	if _, err := search.Submit(0); err == nil || !strings.Contains(err.Error(), "required") {
+		t.Fatalf("expected the empty required field to be refused, got %v", err)
+	}
+	for field, value := range map[int]string{1: "go forms", 3: "Newest", 4: "no", 5: "s"} {
+		if err := search.Field(field).Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := search.Field(3).Set("oldest"); err == nil {
+		t.Fatal("expected an error for an unknown option")
+	}
+	submission, err := search.Submit(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submission.Method != "GET" || submission.URL != "https://example.com/search?q=go+forms&lang=en&sort=new&size=s&note=line+one%0D%0Aline+two" {
+		t.Fatalf("unexpected GET submission: %+v", submission)
+	}
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lucky":
+			r.ParseForm()
+			received = append(received, r.Method+" "+r.Header.Get("Origin")+" "+r.PostForm.Encode())
+			http.Redirect(w, r, "/result", http.StatusSeeOther)
+		case "/upload":
+			file, header, err := r.FormFile("attachment")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			received = append(received, r.FormValue("title")+" "+header.Filename+" "+string(data))
+			w.Write([]byte("<html><body>Upload complete, thank you for the report</body></html>"))
+		default:
+			received = append(received, r.Method+" "+r.URL.Path)
+			w.Write([]byte("<html><body>Results of the search are shown here</body></html>"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	forms = ParseForms(doc, server.URL+"/start")
+	forms[0].Field(1).Set("brauser")
+	submission, err = forms[0].Submit(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Fetch(context.Background(), submission.URL, submission.FetchOptions(server.URL+"/start"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(result.FinalURL, "/result") {
+		t.Fatalf("expected the 303 to be followed, got %s", result.FinalURL)
+	}
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(path, []byte("attached text"), 0644)
+	if err := forms[1].Field(2).Set(path); err != nil {
+		t.Fatal(err)
+	}
+	submission, err = forms[1].Submit(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(submission.ContentType, "multipart/form-data; boundary=") {
+		t.Fatalf("unexpected content type %s", submission.ContentType)
+	}
+	if _, err := client.Fetch(context.Background(), submission.URL, submission.FetchOptions("")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"POST " + server.URL + " lang=en&lucky=1&note=line+one%0D%0Aline+two&q=brauser&safe=on&size=m&sort=top",
+		"GET /result",
+		"report notes.txt attached text",
+	}
+	if strings.Join(received, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("server received:\n%s\nwant:\n%s", strings.Join(received, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestFileFieldIgnoresPageValue checks that a page cannot make a form upload a
+// local file by giving a file field a value
+func TestFileFieldIgnoresPageValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	os.WriteFile(path, []byte("secret key"), 0600)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<form method="post" enctype="multipart/form-data" action="/steal">
+<input name="q" value="x"><input type="file" name="f" value="` + path + `" style="display:none"></form>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := ParseForms(doc, "https://example.com/")[0]
+	if value := form.FieldByName("f").Value; value != "" {
+		t.Fatalf("expected the file field to start empty, got %q", value)
+	}
+	submission, err := form.Submit(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(submission.Body), "secret key") {
+		t.Fatal("the page's file value was uploaded")
+	}
+}
+
+// TestFormLocalAction checks that a remote page cannot submit a form to a
+// local file or a data: URL, through the form or a submit button
+func TestFormLocalAction(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<form action="file:///etc/passwd"><input name="q"></form>
+<form action="/search"><button formaction="data:text/html,hi">Go</button></form>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, form := range ParseForms(doc, "https://example.com/") {
+		var violation *PolicyViolationError
+		if _, err := form.Submit(0); !errors.As(err, &violation) {
+			t.Errorf("form %d: expected a policy violation, got %v", form.Number, err)
+		}
+	}
+	for _, form := range ParseForms(doc, "file:///tmp/page.html") {
+		if _, err := form.Submit(0); err != nil {
+			t.Errorf("form %d of a local page: %v", form.Number, err)
+		}
+	}
+}
+
+// TestRefererPolicy checks that the Referer follows strict-origin-when-cross-origin
+func TestRefererPolicy(t *testing.T) {
+	tests := []struct{ referer, target, want string }{
+		{"https://a.example/path?q=1#top", "https://a.example/next", "https://a.example/path?q=1"},
+		{"https://a.example/path?q=1", "https://b.example/", "https://a.example/"},
+		{"https://a.example/path?q=1", "http://a.example/", ""},
+		{"http://a.example/path", "https://b.example/", "http://a.example/"},
+	}
+	for _, test := range tests {
+		if got := refererFor(test.referer, test.target); got != test.want {
+			t.Errorf("refererFor(%q, %q) = %q, want %q", test.referer, test.target, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is the root of an HTTP Archive 1.2 document
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds the recorded entries of a HAR document
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that wrote a HAR document
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single recorded request and response
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest describes a recorded request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes a recorded response
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARCookie describes a cookie sent or received
+type HARCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARNameValue is a header or query string parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData holds a recorded request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent holds a response body with content codings removed. Binary
+// bodies are stored base64 encoded.
+type HARContent struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text"`
+	Encoding    string `json:"encoding,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// HARTimings splits the time of an entry into phases, in milliseconds
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARMissError is returned during replay for a request that is not in the archive
+type HARMissError struct {
+	Method string
+	URL    string
+}
+
+func (e *HARMissError) Error() string {
+	return fmt.Sprintf("no recorded response for %s %s", e.Method, e.URL)
+}
+
+// LoadHAR reads a HAR document from a file
+func LoadHAR(path string) (*HAR, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAR file: %v", err)
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %v", err)
+	}
+	return &har, nil
+}
+
+// HARRecorder collects every request and response passing through a client
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+// NewHARRecorder creates an empty recorder
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// HAR returns a HAR document with all entries recorded so far
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]*HAREntry, len(r.entries))
+	copy(entries, r.entries)
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "Brauser", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// Save writes the recorded session to a HAR file
+func (r *HARRecorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode HAR: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write HAR file: %v", err)
+	}
+	return nil
+}
+
+// add appends a finished entry
+func (r *HARRecorder) add(entry *HAREntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// harRecordingTransport is the http.RoundTripper layer feeding a HARRecorder.
+// Bodies larger than limit are left out of the archive; 0 disables the limit.
+type harRecordingTransport struct {
+	recorder *HARRecorder
+	limit    int64
+	next     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *harRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := &HAREntry{
+		StartedDateTime: time.Now().Format(time.RFC3339Nano),
+		Request:         harRequest(req),
+	}
+
+	// Keep a copy of the request body for the archive
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		entry.Request.BodySize = len(body)
+		entry.Request.PostData = &HARPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(body),
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	wait := time.Since(start)
+
+	entry.Response = HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     harHeaders(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	entry.Timings.Wait = milliseconds(wait)
+
+	// The entry is completed once the caller has consumed the body
+	resp.Body = &harRecordingBody{
+		body:      resp.Body,
+		limit:     t.limit,
+		truncated: t.limit > 0 && resp.ContentLength > t.limit,
+		finish: func(raw []byte, size int64, truncated bool) {
+			receive := time.Since(start) - wait
+			entry.Timings.Receive = milliseconds(receive)
+			entry.Time = milliseconds(time.Since(start))
+			entry.Response.BodySize = int(size)
+			if truncated {
+				entry.Response.Content = omittedContent(resp.Header, t.limit)
+			} else {
+				entry.Response.Content = harContent(raw, resp.Header, t.limit)
+			}
+			t.recorder.add(entry)
+		},
+	}
+	return resp, nil
+}
+
+// harRecordingBody buffers a response body as it is read, dropping the buffer
+// once more than limit bytes have arrived
+type harRecordingBody struct {
+	body      io.ReadCloser
+	limit     int64
+	buffer    bytes.Buffer
+	size      int64
+	truncated bool
+	finish    func(raw []byte, size int64, truncated bool)
+	finished  bool
+}
+
+// Read implements io.Reader
+func (b *harRecordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.size += int64(n)
+	if b.limit > 0 && b.size > b.limit && !b.truncated {
+		b.truncated = true
+		b.buffer = bytes.Buffer{}
+	}
+	if !b.truncated {
+		b.buffer.Write(p[:n])
+	}
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+// Close implements io.Closer, recording whatever was read so far
+func (b *harRecordingBody) Close() error {
+	b.done()
+	return b.body.Close()
+}
+
+// done hands the buffered body to the recorder once
+func (b *harRecordingBody) done() {
+	if !b.finished {
+		b.finished = true
+		b.finish(b.buffer.Bytes(), b.size, b.truncated)
+	}
+}
+
+// HARReplayTransport is an http.RoundTripper that answers requests from a HAR
+// document instead of the network. Requests are matched by method, URL and
+// request body; entries recorded several times are replayed in order, and the
+// last one is reused once all have been served.
+type HARReplayTransport struct {
+	mu      sync.Mutex
+	entries []*HAREntry
+	served  map[*HAREntry]bool
+}
+
+// NewHARReplayTransport creates a replay transport for the given archive
+func NewHARReplayTransport(har *HAR) *HARReplayTransport {
+	return &HARReplayTransport{
+		entries: har.Log.Entries,
+		served:  make(map[*HAREntry]bool),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *HARReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body string
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+
+	entry := t.match(req.Method, req.URL.String(), body)
+	if entry == nil {
+		return nil, &HARMissError{Method: req.Method, URL: req.URL.String()}
+	}
+
+	if entry.Response.Content.Size < 0 {
+		return nil, fmt.Errorf("failed to replay %s: %s", req.URL, entry.Response.Content.Comment)
+	}
+	content, err := entry.Response.Content.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// The archive stores decoded content, so content coding headers no longer apply
+	header := make(http.Header)
+	for _, h := range entry.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(content)))
+
+	proto := entry.Response.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+
+	return &http.Response{
+		Status:        strings.TrimSpace(fmt.Sprintf("%d %s", entry.Response.Status, entry.Response.StatusText)),
+		StatusCode:    entry.Response.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}, nil
+}
+
+// match finds the next recorded entry for a request
+func (t *HARReplayTransport) match(method, url, body string) *HAREntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var last *HAREntry
+	for _, entry := range t.entries {
+		if entry.Request.Method != method || entry.Request.URL != url {
+			continue
+		}
+		if entry.Request.PostData != nil && entry.Request.PostData.Text != body {
+			continue
+		}
+		if !t.served[entry] {
+			t.served[entry] = true
+			return entry
+		}
+		last = entry
+	}
+	return last
+}
+
+// bytes returns the decoded response body
+func (c HARContent) bytes() ([]byte, error) {
+	if c.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(c.Text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content in HAR: %v", err)
+		}
+		return data, nil
+	}
+	return []byte(c.Text), nil
+}
+
+// harRequest converts a request to its HAR form, without the body
+func harRequest(req *http.Request) HARRequest {
+	return HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: harHeaders(req.URL.Query()),
+		HeadersSize: -1,
+	}
+}
+
+// harContent converts a raw response body, removing content codings when
+// possible. A body that decodes to more than limit bytes is omitted.
+func harContent(raw []byte, header http.Header, limit int64) HARContent {
+	content := HARContent{MimeType: header.Get("Content-Type")}
+
+	data := raw
+	if encoding := header.Get("Content-Encoding"); encoding != "" {
+		if reader, err := decodeContentEncoding(bytes.NewReader(raw), encoding); err == nil {
+			decoded, err := ReadLimited(reader, limit, "")
+			reader.Close()
+			var tooLarge *TooLargeError
+			if errors.As(err, &tooLarge) {
+				return omittedContent(header, limit)
+			}
+			if err == nil {
+				data = decoded
+				content.Compression = len(decoded) - len(raw)
+			}
+		}
+	}
+
+	content.Size = len(data)
+	if utf8.Valid(data) && isTextContentType(content.MimeType) {
+		content.Text = string(data)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(data)
+		content.Encoding = "base64"
+	}
+	return content
+}
+
+// omittedContent stands in for a body too large to keep in the archive
+func omittedContent(header http.Header, limit int64) HARContent {
+	return HARContent{
+		Size:     -1,
+		MimeType: header.Get("Content-Type"),
+		Comment:  fmt.Sprintf("body larger than %d bytes omitted", limit),
+	}
+}
+
+// harHeaders converts headers or query parameters to name/value pairs, sorted by name
+func harHeaders(header map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// harCookies converts cookies to their HAR form
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	result := []HARCookie{}
+	for _, cookie := range cookies {
+		result = append(result, HARCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return result
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
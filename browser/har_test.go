@@ -0,0 +1,153 @@
+package browser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHARRoundTrip checks that a recorded session saved to a file is answered
+// by HARReplayTransport with the same, decoded responses
+func TestHARRoundTrip(t *testing.T) {
+	page := "<html><head><title>Recorded</title></head><body>" + strings.Repeat("<p>Recorded page.</p>", 20) + "</body></html>"
+	logo := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff\xfe")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte(page))
+			zw.Close()
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(logo)
+		case "/search":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(r.Method + " " + string(body)))
+		}
+	}))
+
+	client := NewClient()
+	recorder := client.RecordHAR()
+	for _, target := range []string{"/page", "/logo.png"} {
+		if _, err := client.Fetch(context.Background(), server.URL+target, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := client.Fetch(context.Background(), server.URL+"/search", FetchOptions{
+		Method:      http.MethodPost,
+		Body:        []byte("q=go"),
+		ContentType: "application/x-www-form-urlencoded",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "session.har")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	har, err := LoadHAR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 3 || har.Log.Entries[1].Response.Content.Encoding != "base64" {
+		t.Fatalf("expected three entries with the image in base64, got %+v", har.Log.Entries)
+	}
+	replay := &http.Client{Transport: NewHARReplayTransport(har)}
+	get := func(method, target, body string) (*http.Response, []byte, error) {
+		req, _ := http.NewRequest(method, server.URL+target, strings.NewReader(body))
+		if body == "" {
+			req.Body = http.NoBody
+		}
+		resp, err := replay.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return resp, data, err
+	}
+
+	resp, data, err := get(http.MethodGet, "/page", "")
+	if err != nil || string(data) != page || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected replay of the page: %q, %v", data, err)
+	}
+	resp, data, err = get(http.MethodGet, "/logo.png", "")
+	if err != nil || !bytes.Equal(data, logo) || resp.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected replay of the image: %q, %v", data, err)
+	}
+	if _, data, err = get(http.MethodPost, "/search", "q=go"); err != nil || string(data) != "POST q=go" {
+		t.Fatalf("unexpected replay of the form: %q, %v", data, err)
+	}
+
+	var miss *HARMissError
+	if _, _, err := get(http.MethodPost, "/search", "q=rust"); !errors.As(err, &miss) {
+		t.Fatalf("expected a miss for another form body, got %v", err)
+	}
+	if _, _, err := get(http.MethodGet, "/other", ""); !errors.As(err, &miss) {
+		t.Fatalf("expected a miss for an unrecorded URL, got %v", err)
+	}
+}
+
+// TestHARSizeLimit checks that bodies over the document limit, before or after
+// decoding, are left out of the archive with a comment
+func TestHARSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		switch r.URL.Path {
+		case "/declared":
+			w.Header().Set("Content-Length", "4096")
+			w.Write(bytes.Repeat([]byte("a"), 4096))
+		case "/chunked":
+			for i := 0; i < 4; i++ {
+				w.Write(bytes.Repeat([]byte("b"), 1024))
+				w.(http.Flusher).Flush()
+			}
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write(make([]byte, 1<<20))
+			zw.Close()
+		case "/small":
+			w.Write([]byte("small"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetMaxDocumentSize(1024)
+	recorder := client.RecordHAR()
+	for _, target := range []string{"/declared", "/chunked", "/bomb", "/small"} {
+		client.Fetch(context.Background(), server.URL+target, FetchOptions{})
+	}
+
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 4 {
+		t.Fatalf("expected four entries, got %d", len(entries))
+	}
+	for _, entry := range entries[:3] {
+		content := entry.Response.Content
+		if content.Text != "" || content.Size != -1 || !strings.Contains(content.Comment, "omitted") {
+			t.Errorf("%s: expected the body to be omitted, got size %d, %d bytes of text, comment %q", entry.Request.URL, content.Size, len(content.Text), content.Comment)
+		}
+	}
+	if content := entries[3].Response.Content; content.Text != "small" || content.Comment != "" {
+		t.Errorf("expected the small body to be kept, got %+v", content)
+	}
+
+	replay := &http.Client{Transport: NewHARReplayTransport(recorder.HAR())}
+	if _, err := replay.Get(server.URL + "/bomb"); err == nil || !strings.Contains(err.Error(), "omitted") {
+		t.Errorf("expected replaying an omitted body to fail, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package browser
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Default size limits, applied to the decoded body
+const (
+	DefaultMaxDocumentSize int64 = 10 << 20
+	DefaultMaxImageSize    int64 = 5 << 20
+)
+
+// ReadLimited reads all of r but fails with a *TooLargeError as soon as more
+// than limit bytes arrive. A limit of 0 or less disables the check.
+func ReadLimited(r io.Reader, limit int64, url string) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &TooLargeError{URL: url, Limit: limit}
+	}
+	return data, nil
+}
+
+// CopyLimited copies r to w like ReadLimited reads it
+func CopyLimited(w io.Writer, r io.Reader, limit int64, url string) (int64, error) {
+	if limit <= 0 {
+		return io.Copy(w, r)
+	}
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, &TooLargeError{URL: url, Limit: limit}
+	}
+	return n, nil
+}
+
+// CheckContentLength rejects a response early when its declared length is over the limit
+func CheckContentLength(resp *http.Response, limit int64) error {
+	if limit > 0 && resp.ContentLength > limit && resp.Header.Get("Content-Encoding") == "" {
+		return &TooLargeError{URL: resp.Request.URL.String(), Limit: limit}
+	}
+	return nil
+}
+
+// ParseSize parses a byte size such as "512", "100KB", "10MB" or "1GB"
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// shouldStream reports whether a response is saved to disk instead of being
+// loaded as a page: attachments, content that cannot be rendered, judged by its
+// type and first bytes, or any content declared larger than the limit
+func shouldStream(resp *http.Response, head []byte, limit int64) bool {
+	if disposition, _, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && disposition == "attachment" {
+		return true
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/octet-stream" {
+		return true
+	}
+	if DetectContentKind(contentType, head) == KindBinary {
+		return true
+	}
+	return limit > 0 && resp.ContentLength > limit
+}
+
+// streamToFile writes a decoded response body into dir and returns the file path and size
+func streamToFile(dir string, resp *http.Response, body io.Reader, limit int64, progress DownloadProgress) (string, int64, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create download directory: %v", err)
+	}
+	file, err := createUnique(dir, fileNameFor(resp.Header, resp.Request.URL))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create download file: %v", err)
+	}
+
+	total := int64(-1)
+	if resp.Header.Get("Content-Encoding") == "" {
+		total = resp.ContentLength
+	}
+	writer := &progressWriter{w: file, name: filepath.Base(file.Name()), total: total, report: progress}
+	n, err := CopyLimited(writer, body, limit, resp.Request.URL.String())
+	writer.finish()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", n, err
+	}
+	return file.Name(), n, nil
+}
+
+// fileNameFor picks a file name from Content-Disposition or the URL path
+func fileNameFor(header http.Header, u *url.URL) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if name := filepath.Base(params["filename"]); name != "." && name != "/" && name != "" {
+			return name
+		}
+	}
+	if u != nil && u.Scheme != "data" {
+		if name := path.Base(u.Path); name != "." && name != "/" && name != "" {
+			return name
+		}
+	}
+	return "download"
+}
+
+// SavePage writes the body of a loaded page into dir, named after its URL, and returns the file path
+func SavePage(dir string, page *PageResult) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create download directory: %v", err)
+	}
+	u, _ := url.Parse(page.FinalURL)
+	file, err := createUnique(dir, fileNameFor(page.Header, u))
+	if err != nil {
+		return "", fmt.Errorf("failed to create download file: %v", err)
+	}
+	_, err = file.WriteString(page.Content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to save download: %v", err)
+	}
+	return file.Name(), nil
+}
+
+// createUnique creates name in dir, adding a counter when the file already exists
+func createUnique(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	if strings.HasSuffix(strings.TrimSuffix(name, ext), ".tar") {
+		ext = ".tar" + ext
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, candidate), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
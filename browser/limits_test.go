@@ -0,0 +1,62 @@
+package browser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSizeLimits checks that the document limit applies after decompression
+// and that binary responses can be streamed to disk instead
+func TestSizeLimits(t *testing.T) {
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	gz.Write(make([]byte, 2<<20))
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bomb":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(bomb.Bytes())
+		case "/file.bin":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("binary data"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetMaxDocumentSize(1 << 20)
+	_, err := client.Fetch(context.Background(), server.URL+"/bomb", FetchOptions{})
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1<<20 || IsRetryable(err) {
+		t.Fatalf("expected TooLargeError for the decompressed body, got %v", err)
+	}
+
+	dir := t.TempDir()
+	client.SetStreamDir(dir)
+	for _, name := range []string{"file.bin", "file (1).bin"} {
+		result, err := client.Fetch(context.Background(), server.URL+"/file.bin", FetchOptions{EnableRetry: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.SavedPath != filepath.Join(dir, name) || result.SavedSize != 11 || result.Content != "" {
+			t.Fatalf("unexpected download result: %q, %d bytes", result.SavedPath, result.SavedSize)
+		}
+		if data, _ := os.ReadFile(result.SavedPath); string(data) != "binary data" {
+			t.Fatalf("saved file contains %q", data)
+		}
+	}
+
+	if size, err := ParseSize("1.5MB"); err != nil || size != 3<<19 {
+		t.Fatalf("ParseSize(1.5MB) = %d, %v", size, err)
+	}
+}
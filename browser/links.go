@@ -0,0 +1,164 @@
+package browser
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// LinkNumberAttr is the attribute NumberLinks stores the number of a link in,
+// so the renderer can show it next to the link text
+const LinkNumberAttr = "data-brauser-link"
+
+// linksNumberedAttr marks the document node of a page whose links NumberLinks
+// numbered; pages cannot put attributes on the document node itself
+const linksNumberedAttr = "data-brauser-numbered"
+
+// NumberedLink is a link of a page with the number that follows it
+type NumberedLink struct {
+	Number int
+	Text   string
+	URL    string
+	Node   *html.Node // The a element
+}
+
+// NumberLinks numbers the visible links of a page in document order; links to
+// the same URL share a number. Numbers NumberLinks stored in the document before
+// are kept, so the renderer and the navigator agree on them, and links added
+// since are numbered after them. Numbers the page itself put into its HTML are
+// discarded, so a page cannot give a link the number shown next to another.
+// Links from a remote page to file: and data: URLs are not numbered.
+func NumberLinks(doc *goquery.Document, baseURL string) []NumberedLink {
+	base, _ := url.Parse(baseURL)
+	anchors := doc.Find("a[href]")
+
+	root := documentNode(doc)
+	if root == nil || !hasNodeAttr(root, linksNumberedAttr) {
+		doc.Find("[" + LinkNumberAttr + "]").RemoveAttr(LinkNumberAttr)
+		if root != nil {
+			root.Attr = append(root.Attr, html.Attribute{Key: linksNumberedAttr})
+		}
+	}
+
+	last := 0
+	numbers := map[string]int{}
+	anchors.Each(func(i int, s *goquery.Selection) {
+		if number, err := strconv.Atoi(s.AttrOr(LinkNumberAttr, "")); err == nil {
+			last = max(last, number)
+		}
+	})
+
+	var links []NumberedLink
+	listed := map[int]bool{}
+	anchors.Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || href == "#" || strings.HasPrefix(strings.ToLower(href), "javascript:") || IsHidden(s.Nodes[0]) {
+			return
+		}
+		text := linkLabel(s)
+		if text == "" {
+			return
+		}
+		target := resolveAgainst(base, href)
+		if CheckNavigation(baseURL, target) != nil {
+			return
+		}
+
+		number, err := strconv.Atoi(s.AttrOr(LinkNumberAttr, ""))
+		if err != nil {
+			if known, ok := numbers[target]; ok {
+				number = known
+			} else {
+				last++
+				number = last
+			}
+			s.SetAttr(LinkNumberAttr, strconv.Itoa(number))
+		}
+		if _, ok := numbers[target]; !ok {
+			numbers[target] = number
+		}
+		if !listed[number] {
+			listed[number] = true
+			links = append(links, NumberedLink{Number: number, Text: text, URL: target, Node: s.Nodes[0]})
+		}
+	})
+	return links
+}
+
+// documentNode returns the document node above the nodes of doc, or nil for a
+// fragment that is not part of a parsed page
+func documentNode(doc *goquery.Document) *html.Node {
+	if len(doc.Nodes) == 0 {
+		return nil
+	}
+	n := doc.Nodes[0]
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	if n.Type != html.DocumentNode {
+		return nil
+	}
+	return n
+}
+
+// hasNodeAttr reports whether a node has an attribute
+func hasNodeAttr(n *html.Node, name string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// linkLabel returns the text of a link, or for image links the image's alt text
+func linkLabel(s *goquery.Selection) string {
+	if text := collapseSpace(s.Text()); text != "" {
+		return text
+	}
+	if alt := collapseSpace(s.Find("img[alt]").First().AttrOr("alt", "")); alt != "" {
+		return alt
+	}
+	return collapseSpace(firstAttr(s, "aria-label", "title"))
+}
+
+// IsHidden reports whether an element or one of its ancestors is hidden from
+// readers by its attributes, or is not part of the page body
+func IsHidden(n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		switch n.Data {
+		case "head", "script", "style", "template", "noscript":
+			return true
+		}
+		if hiddenElement(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenElement reports whether an element itself is hidden by its attributes
+func hiddenElement(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if attr.Val == "true" {
+				return true
+			}
+		case "style":
+			style := strings.ReplaceAll(strings.ToLower(attr.Val), " ", "")
+			if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestNumberLinks checks that links are numbered in document order, that the
+// numbers are kept in the document and that new links are numbered after them
+func TestNumberLinks(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<p><a href="/a">First</a> <a href="javascript:void(0)">Script</a> <a href="b">Second</a></p>
+<div hidden><a href="/hidden">Hidden</a></div>
+<a href="/logo"><img src="logo.png" alt="Logo"></a> <a href="/empty"></a>
+<a href="https://example.com/a">First again</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := func(links []NumberedLink) string {
+		var parts []string
+		for _, link := range links {
+			parts = append(parts, fmt.Sprintf("%d %s %s", link.Number, link.Text, link.URL))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	links := NumberLinks(doc, "https://example.com/dir/page")
+	want := "1 First https://example.com/a, 2 Second https://example.com/dir/b, 3 Logo https://example.com/logo"
+	if got := format(links); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+	if number := doc.Find(`a[href="https://example.com/a"]`).AttrOr(LinkNumberAttr, ""); number != "1" {
+		t.Fatalf("expected the repeated link to share number 1, got %q", number)
+	}
+
+	doc.Find("p").First().PrependHtml(`<a href="/new">New</a>`)
+	if got := format(NumberLinks(doc, "https://example.com/dir/page")); got != "4 New https://example.com/new, "+want {
+		t.Fatalf("unexpected numbers after adding a link: %s", got)
+	}
+}
+
+// TestNumberLinksIgnoresPageNumbers checks that a page cannot choose the
+// numbers of its links
+func TestNumberLinksIgnoresPageNumbers(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<a href="https://bank.example/" data-brauser-link="2">Your bank</a>
+<a href="https://evil.example/" data-brauser-link="1">.</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		links := NumberLinks(doc, "https://page.example/")
+		if len(links) != 2 || links[0].Number != 1 || links[0].URL != "https://bank.example/" || links[1].Number != 2 {
+			t.Fatalf("unexpected numbers: %+v", links)
+		}
+		if number := doc.Find(`a[href="https://bank.example/"]`).AttrOr(LinkNumberAttr, ""); number != "1" {
+			t.Fatalf("expected the bank link to be shown as 1, got %q", number)
+		}
+	}
+}
+
+// TestNumberLinksSkipsLocalTargets checks that a remote page cannot offer links
+// to local files or data: URLs, while local pages can
+func TestNumberLinksSkipsLocalTargets(t *testing.T) {
+	page := `<html><body>
+<a href="file:///etc/passwd">Passwords</a> <a href="data:text/html,<h1>Login</h1>">Login</a>
+<a href="/docs">Docs</a>
+</body></html>`
+	for baseURL, want := range map[string]int{
+		"https://example.com/":  1,
+		"file:///tmp/page.html": 3,
+	} {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if links := NumberLinks(doc, baseURL); len(links) != want {
+			t.Errorf("%s: expected %d links, got %+v", baseURL, want, links)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package browser
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NetworkOptions configures the proxy and TLS settings of a Client
+type NetworkOptions struct {
+	ProxyURL       string   // http://, https:// or socks5:// URL with optional user:password; empty uses the environment
+	NoProxy        string   // Comma-separated hosts, domains and CIDR ranges that bypass the proxy
+	CAFiles        []string // PEM files with root certificates trusted in addition to the system pool
+	ClientCertFile string   // PEM certificate presented for mutual TLS
+	ClientKeyFile  string   // PEM private key of the client certificate
+	MinTLSVersion  uint16   // tls.VersionTLS10 to tls.VersionTLS13; 0 keeps the Go default
+	InsecureHosts  []string // Hosts whose certificates are not verified; ".example.com" includes subdomains
+}
+
+// ParseTLSVersion parses a TLS version such as "1.2" into its tls.VersionTLS constant
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls") {
+	case "":
+		return 0, nil
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (use 1.0, 1.1, 1.2 or 1.3)", version)
+}
+
+// ConfigureNetwork applies proxy and TLS options to all requests of this client
+func (c *Client) ConfigureNetwork(opts NetworkOptions) error {
+	proxy, err := newProxyConfig(opts.ProxyURL, opts.NoProxy)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := buildTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	c.proxy = proxy
+	c.baseTransport.Proxy = proxy.transportProxy
+	c.baseTransport.TLSClientConfig = tlsConfig
+	c.baseTransport.CloseIdleConnections()
+
+	c.insecureHosts = opts.InsecureHosts
+	c.insecureTransport = nil
+	if len(opts.InsecureHosts) > 0 {
+		c.insecureTransport = c.baseTransport.Clone()
+		c.insecureTransport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	c.rebuildTransport()
+	return nil
+}
+
+// insecureHostTransport sends requests for the opted-in hosts through a
+// transport that skips certificate verification
+type insecureHostTransport struct {
+	hosts    []string
+	insecure http.RoundTripper
+	next     http.RoundTripper
+}
+
+// RoundTrip picks the transport by the request host
+func (t *insecureHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" && hostMatches(req.URL.Hostname(), t.hosts) {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// proxyConfig decides which proxy, if any, a request is sent through
+type proxyConfig struct {
+	config *httpproxy.Config
+	proxy  func(*url.URL) (*url.URL, error)
+}
+
+// newProxyConfig creates the proxy settings. Without a proxy URL the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+func newProxyConfig(proxyURL, noProxy string) (*proxyConfig, error) {
+	config := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", parsed.Scheme)
+		}
+		config.HTTPProxy = proxyURL
+		config.HTTPSProxy = proxyURL
+	}
+	if noProxy != "" {
+		config.NoProxy = noProxy
+	}
+
+	return &proxyConfig{config: config, proxy: config.ProxyFunc()}, nil
+}
+
+// transportProxy is the Proxy function of the HTTP transport
+func (p *proxyConfig) transportProxy(req *http.Request) (*url.URL, error) {
+	return p.proxy(req.URL)
+}
+
+// addresses returns the host:port addresses of the configured proxies
+func (p *proxyConfig) addresses() []string {
+	var addresses []string
+	for _, proxyURL := range []string{p.config.HTTPProxy, p.config.HTTPSProxy} {
+		if proxyURL != "" && !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		port := parsed.Port()
+		if port == "" {
+			switch parsed.Scheme {
+			case "https":
+				port = "443"
+			case "socks5", "socks5h":
+				port = "1080"
+			default:
+				port = "80"
+			}
+		}
+		addresses = append(addresses, net.JoinHostPort(strings.ToLower(parsed.Hostname()), port))
+	}
+	return addresses
+}
+
+// buildTLSConfig creates the TLS configuration for the given options
+func buildTLSConfig(opts NetworkOptions) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: opts.MinTLSVersion}
+
+	if len(opts.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range opts.CAFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+			}
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		keyFile := opts.ClientKeyFile
+		if keyFile == "" {
+			keyFile = opts.ClientCertFile // Certificate and key in one PEM file
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// hostMatches reports whether host is in the list. Entries starting with a dot
+// or "*." also match all subdomains.
+func hostMatches(host string, hosts []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range hosts {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entry = strings.TrimPrefix(entry, "*")
+		if strings.HasPrefix(entry, ".") {
+			if host == entry[1:] || strings.HasSuffix(host, entry) {
+				return true
+			}
+		} else if host == entry {
+			return true
+		}
+	}
+	return false
+}
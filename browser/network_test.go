@@ -0,0 +1,75 @@
+package browser
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigureNetwork checks extra CA bundles, per-host insecure mode and proxy routing
+func TestConfigureNetwork(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>secure</body></html>"))
+	}))
+	defer server.Close()
+
+	fetch := func(client *Client, url string) error {
+		_, err := client.Fetch(context.Background(), url, FetchOptions{})
+		return err
+	}
+
+	var tlsErr *TLSError
+	if err := fetch(NewClient(), server.URL); !errors.As(err, &tlsErr) {
+		t.Fatalf("expected TLSError for an unknown CA, got %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient()
+	if err := client.ConfigureNetwork(NetworkOptions{CAFiles: []string{caFile}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(client, server.URL); err != nil {
+		t.Fatalf("fetch with extra CA failed: %v", err)
+	}
+
+	client = NewClient()
+	client.ConfigureNetwork(NetworkOptions{InsecureHosts: []string{"127.0.0.1"}})
+	if err := fetch(client, server.URL); err != nil {
+		t.Fatalf("fetch from insecure host failed: %v", err)
+	}
+	client.ConfigureNetwork(NetworkOptions{InsecureHosts: []string{"example.com"}})
+	if err := fetch(client, server.URL); !errors.As(err, &tlsErr) {
+		t.Fatalf("expected TLSError for a host not opted in, got %v", err)
+	}
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String() + " " + r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("<html><body>via proxy</body></html>"))
+	}))
+	defer proxy.Close()
+
+	client = NewClient()
+	proxyURL := "http://user:secret@" + proxy.Listener.Addr().String()
+	if err := client.ConfigureNetwork(NetworkOptions{ProxyURL: proxyURL}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(client, "http://brauser.test/page"); err != nil || proxied != "http://brauser.test/page Basic dXNlcjpzZWNyZXQ=" {
+		t.Fatalf("request not sent through the proxy: %q, %v", proxied, err)
+	}
+	client.ConfigureNetwork(NetworkOptions{ProxyURL: proxyURL, NoProxy: ".test"})
+	proxied = ""
+	fetch(client, "http://brauser.test/page")
+	if proxied != "" {
+		t.Fatalf("NO_PROXY host was sent through the proxy")
+	}
+}
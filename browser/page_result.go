@@ -0,0 +1,78 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FetchOptions controls how Fetch loads a page
+type FetchOptions struct {
+	EnableRetry bool   // Analyze the content and retry while it looks incomplete
+	Revalidate  bool   // Check a cached copy with the origin before using it, as on reload
+	Method      string // GET when empty; other methods are never retried
+	Body        []byte // Request body, for example an encoded form
+	ContentType string // Content-Type of the body
+	Referer     string // Page the request was made from; POST requests also send its origin
+}
+
+// idempotent reports whether the request may be sent again after a failure
+// without repeating a side effect such as a form submission
+func (o FetchOptions) idempotent() bool {
+	return o.Method == "" || o.Method == http.MethodGet || o.Method == http.MethodHead
+}
+
+// Attempt records a single request made while fetching a page
+type Attempt struct {
+	StatusCode    int
+	ContentLength int
+	Duration      time.Duration
+	Err           error // Nil if the attempt returned a page
+}
+
+// PageResult describes a fetched page and how it was obtained
+type PageResult struct {
+	URL              string // URL that was requested
+	FinalURL         string // URL of the response after following redirects
+	StatusCode       int
+	Status           string
+	Header           http.Header
+	ContentType      string
+	Charset          string // Encoding the content was converted from
+	CacheStatus      string // CacheHit, CacheMiss, CacheRevalidated or CacheOffline; empty without cache
+	Content          string // Page content as UTF-8, after site handler processing
+	SavedPath        string // File the body was streamed to instead of Content, see Client.SetStreamDir
+	SavedSize        int64
+	SiteHandler      string // Name of the site handler used, if any
+	ContentRewritten bool   // True if the site handler replaced the original content
+	Attempts         []Attempt
+	Retries          int
+	Duration         time.Duration
+	Analysis         *ContentAnalysis // Nil when content detection is disabled
+	Warnings         []string         // Problems that did not stop the page from loading
+	RedirectChain    []Redirect       // HTTP and client-side redirects from URL to FinalURL, in order
+
+	clientRedirect     string // Target of a meta refresh or JavaScript redirect found in the content
+	clientRedirectKind string
+}
+
+// IsSuccess reports whether the final response had a 2xx status code
+func (r *PageResult) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// MediaType returns the lower-cased media type of the response without parameters
+func (r *PageResult) MediaType() string {
+	mediaType := strings.Split(r.ContentType, ";")[0]
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// siteHandlerName returns a short display name for a site handler
+func siteHandlerName(handler SiteHandler) string {
+	name := fmt.Sprintf("%T", handler)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,178 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// URLRule matches URLs by scheme, host glob, path prefix and regular expression.
+// Empty fields match everything; a rule matches when all set fields match.
+type URLRule struct {
+	Scheme     string // "http" or "https"
+	Host       string // Glob such as "example.com" or "*.example.com"
+	PathPrefix string // Matches the path and everything below it
+	Regex      string // Matched against the whole URL
+
+	regex *regexp.Regexp
+}
+
+// String describes the rule for error messages
+func (r URLRule) String() string {
+	var parts []string
+	if r.Scheme != "" {
+		parts = append(parts, "scheme="+r.Scheme)
+	}
+	if r.Host != "" {
+		parts = append(parts, "host="+r.Host)
+	}
+	if r.PathPrefix != "" {
+		parts = append(parts, "path="+r.PathPrefix)
+	}
+	if r.Regex != "" {
+		parts = append(parts, "regex="+r.Regex)
+	}
+	if len(parts) == 0 {
+		return "any URL"
+	}
+	return strings.Join(parts, " ")
+}
+
+// matches reports whether the rule matches u
+func (r *URLRule) matches(u *url.URL) bool {
+	if r.Scheme != "" && !strings.EqualFold(r.Scheme, u.Scheme) {
+		return false
+	}
+	if r.Host != "" {
+		// "evil.com." names the same host as "evil.com"
+		pattern := strings.TrimSuffix(strings.ToLower(r.Host), ".")
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		matched, err := path.Match(pattern, host)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	if r.PathPrefix != "" && !pathHasPrefix(u.Path, r.PathPrefix) {
+		return false
+	}
+	if r.regex != nil && !r.regex.MatchString(u.String()) {
+		return false
+	}
+	return true
+}
+
+// pathHasPrefix reports whether a decoded URL path is prefix or below it, after
+// removing dot segments, so "/%61dmin/x" and "/a/../admin" match "/admin" but
+// "/administrator" does not
+func pathHasPrefix(urlPath, prefix string) bool {
+	urlPath = path.Clean("/" + urlPath)
+	prefix = strings.TrimSuffix(path.Clean("/"+prefix), "/")
+	return prefix == "" || urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// PolicyViolationError is returned when the URL policy does not permit a URL
+type PolicyViolationError struct {
+	URL  string
+	Rule string // The deny rule that matched, empty if no allow rule matched
+}
+
+func (e *PolicyViolationError) Error() string {
+	if e.Rule != "" {
+		return fmt.Sprintf("URL policy denies %s (%s)", e.URL, e.Rule)
+	}
+	return fmt.Sprintf("URL policy does not allow %s", e.URL)
+}
+
+// URLPolicy decides which URLs may be visited. Deny rules win over allow rules;
+// when there are allow rules, a URL must match one of them.
+type URLPolicy struct {
+	allow []URLRule
+	deny  []URLRule
+}
+
+// NewURLPolicy creates a policy and compiles the regular expressions of its rules
+func NewURLPolicy(allow, deny []URLRule) (*URLPolicy, error) {
+	policy := &URLPolicy{}
+	for _, list := range []struct {
+		rules []URLRule
+		dest  *[]URLRule
+	}{{allow, &policy.allow}, {deny, &policy.deny}} {
+		for _, rule := range list.rules {
+			if rule.Regex != "" {
+				regex, err := regexp.Compile(rule.Regex)
+				if err != nil {
+					return nil, fmt.Errorf("invalid URL policy regex %q: %v", rule.Regex, err)
+				}
+				rule.regex = regex
+			}
+			if _, err := path.Match(rule.Host, ""); err != nil {
+				return nil, fmt.Errorf("invalid URL policy host pattern %q: %v", rule.Host, err)
+			}
+			*list.dest = append(*list.dest, rule)
+		}
+	}
+	return policy, nil
+}
+
+// Check returns a *PolicyViolationError if the policy does not permit rawURL
+func (p *URLPolicy) Check(rawURL string) error {
+	if p == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &PolicyViolationError{URL: rawURL, Rule: "unparsable URL"}
+	}
+	return p.checkURL(u)
+}
+
+// checkURL is Check for a parsed URL
+func (p *URLPolicy) checkURL(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+	for i := range p.deny {
+		if p.deny[i].matches(u) {
+			return &PolicyViolationError{URL: u.String(), Rule: "deny " + p.deny[i].String()}
+		}
+	}
+	if len(p.allow) == 0 {
+		return nil
+	}
+	for i := range p.allow {
+		if p.allow[i].matches(u) {
+			return nil
+		}
+	}
+	return &PolicyViolationError{URL: u.String()}
+}
+
+// policyTransport refuses requests that the URL policy does not permit
+type policyTransport struct {
+	policy *URLPolicy
+	next   http.RoundTripper
+}
+
+// RoundTrip checks the request URL before passing the request on
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// SetURLPolicy restricts every request of this client, including redirects and
+// images fetched through HTTPClient, to the URLs the policy permits. A nil
+// policy removes the restriction.
+func (c *Client) SetURLPolicy(policy *URLPolicy) {
+	c.urlPolicy = policy
+	c.rebuildTransport()
+}
+
+// URLPolicy returns the URL policy of this client, or nil
+func (c *Client) URLPolicy() *URLPolicy {
+	return c.urlPolicy
+}
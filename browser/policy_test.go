@@ -0,0 +1,80 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestURLPolicy checks rule matching and that the client refuses denied URLs,
+// including redirect targets
+func TestURLPolicy(t *testing.T) {
+	policy, err := NewURLPolicy(
+		[]URLRule{{Scheme: "https", Host: "*.example.com"}, {Host: "127.0.0.1"}},
+		[]URLRule{{PathPrefix: "/admin"}, {Regex: `\.exe$`}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rawURL, allowed := range map[string]bool{
+		"https://docs.example.com/guide":     true,
+		"https://docs.example.com./guide":    true,
+		"http://docs.example.com/guide":      false,
+		"https://example.com/":               false,
+		"https://www.example.com/admin/":     false,
+		"https://www.example.com/admin":      false,
+		"https://www.example.com/%61dmin/x":  false,
+		"https://www.example.com/a/../admin": false,
+		"https://www.example.com//admin/x":   false,
+		"https://www.example.com/administer": true,
+		"https://www.example.com/setup.exe":  false,
+		"https://www.example.com/setup.exe?": true,
+		"https://evil.com/?www.example.com":  false,
+	} {
+		if err := policy.Check(rawURL); (err == nil) != allowed {
+			t.Errorf("Check(%s) = %v, want allowed=%v", rawURL, err, allowed)
+		}
+	}
+
+	// A trailing dot names the same host and must not slip past a deny rule
+	denyHost, err := NewURLPolicy(nil, []URLRule{{Host: "evil.com"}, {Host: "*.tracker.net."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rawURL, allowed := range map[string]bool{
+		"https://evil.com/":       false,
+		"https://evil.com./":      false,
+		"https://EVIL.COM.:443/":  false,
+		"https://ads.tracker.net": false,
+		"https://good.com./":      true,
+	} {
+		if err := denyHost.Check(rawURL); (err == nil) != allowed {
+			t.Errorf("Check(%s) = %v, want allowed=%v", rawURL, err, allowed)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://localhost"+r.Host[strings.LastIndex(r.Host, ":"):]+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html><body>ok</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetURLPolicy(policy)
+	if _, err := client.Fetch(context.Background(), server.URL+"/", FetchOptions{}); err != nil {
+		t.Fatalf("allowed URL failed: %v", err)
+	}
+	var violation *PolicyViolationError
+	if _, err := client.Fetch(context.Background(), server.URL+"/admin/users", FetchOptions{}); !errors.As(err, &violation) || violation.Rule == "" {
+		t.Fatalf("expected a deny rule violation, got %v", err)
+	}
+	if _, err := client.Fetch(context.Background(), server.URL+"/away", FetchOptions{}); !errors.As(err, &violation) || IsRetryable(err) {
+		t.Fatalf("expected the redirect target to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package browser
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// PolitenessPolicy limits how hard the client works a single host
+type PolitenessPolicy struct {
+	RequestsPerSecond    float64       // Requests started per second and host; 0 means no limit
+	MaxConcurrentPerHost int           // Requests in flight per host; 0 means no limit
+	Jitter               time.Duration // Random extra wait of up to this long before each request
+	RespectCrawlDelay    bool          // Wait at least the Crawl-delay from the host's robots.txt
+	MaxDelay             time.Duration // Cap for Crawl-delay and Retry-After holds; 0 means no cap
+}
+
+// DefaultPolitenessPolicy returns settings suitable for batch jobs
+func DefaultPolitenessPolicy() PolitenessPolicy {
+	return PolitenessPolicy{
+		RequestsPerSecond:    1,
+		MaxConcurrentPerHost: 2,
+		Jitter:               500 * time.Millisecond,
+		RespectCrawlDelay:    true,
+		MaxDelay:             30 * time.Second,
+	}
+}
+
+// hostState tracks the requests made to one host
+type hostState struct {
+	slots        chan struct{} // Nil without a concurrency limit
+	next         time.Time     // Earliest start of the next request
+	blockedUntil time.Time     // Set from Retry-After on 429 and 503 responses
+}
+
+// crawlDelayFunc returns the Crawl-delay the host of u asks the given User-Agent to keep
+type crawlDelayFunc func(ctx context.Context, u *url.URL, userAgent string) time.Duration
+
+// politeTransport delays and limits requests per host according to a PolitenessPolicy.
+// It also applies the request timeout, so that time spent waiting for a turn
+// does not count against it.
+type politeTransport struct {
+	policy     PolitenessPolicy
+	timeout    time.Duration
+	crawlDelay crawlDelayFunc // Nil when no Crawl-delay source is available
+	next       http.RoundTripper
+	mu         sync.Mutex
+	hosts      map[string]*hostState
+}
+
+// newPoliteTransport creates a politeness layer in front of next
+func newPoliteTransport(policy PolitenessPolicy, timeout time.Duration, crawlDelay crawlDelayFunc, next http.RoundTripper) *politeTransport {
+	return &politeTransport{
+		policy:     policy,
+		timeout:    timeout,
+		crawlDelay: crawlDelay,
+		next:       next,
+		hosts:      make(map[string]*hostState),
+	}
+}
+
+// host returns the state for the given host, creating it on first use
+func (t *politeTransport) host(name string) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.hosts[name]
+	if !ok {
+		state = &hostState{}
+		if t.policy.MaxConcurrentPerHost > 0 {
+			state.slots = make(chan struct{}, t.policy.MaxConcurrentPerHost)
+		}
+		t.hosts[name] = state
+	}
+	return state
+}
+
+// RoundTrip waits for a free slot and the host's next turn, then sends the request
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	state := t.host(req.URL.Host)
+
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if state.slots != nil {
+			<-state.slots
+		}
+	}
+
+	interval := t.interval(ctx, req)
+	if wait := t.reserve(state, interval); wait > 0 {
+		if wait >= time.Second {
+			log.Printf("Waiting %v before requesting %s", wait.Round(time.Millisecond), req.URL.Host)
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if delay := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); delay > 0 {
+			t.hold(state, delay)
+		}
+	}
+
+	resp.Body = &politeBody{ReadCloser: resp.Body, done: func() {
+		cancel()
+		release()
+	}}
+	return resp, nil
+}
+
+// interval returns the minimum time between two requests to the host of req
+func (t *politeTransport) interval(ctx context.Context, req *http.Request) time.Duration {
+	var interval time.Duration
+	if t.policy.RequestsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / t.policy.RequestsPerSecond)
+	}
+	if t.policy.RespectCrawlDelay && t.crawlDelay != nil {
+		if delay := t.capDelay(t.crawlDelay(ctx, req.URL, req.Header.Get("User-Agent"))); delay > interval {
+			interval = delay
+		}
+	}
+	return interval
+}
+
+// reserve books the host's next turn and returns how long to wait for it
+func (t *politeTransport) reserve(state *hostState, interval time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	start := now
+	if state.next.After(start) {
+		start = state.next
+	}
+	if state.blockedUntil.After(start) {
+		start = state.blockedUntil
+	}
+	if t.policy.Jitter > 0 {
+		start = start.Add(time.Duration(rand.Int63n(int64(t.policy.Jitter))))
+	}
+	state.next = start.Add(interval)
+	return start.Sub(now)
+}
+
+// hold keeps all requests to a host waiting until the Retry-After delay passed
+func (t *politeTransport) hold(state *hostState, delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until := time.Now().Add(t.capDelay(delay))
+	if until.After(state.blockedUntil) {
+		state.blockedUntil = until
+	}
+}
+
+// capDelay limits a server-requested delay to MaxDelay
+func (t *politeTransport) capDelay(delay time.Duration) time.Duration {
+	if t.policy.MaxDelay > 0 && delay > t.policy.MaxDelay {
+		return t.policy.MaxDelay
+	}
+	return delay
+}
+
+// politeBody frees the host slot once the response body is closed
+type politeBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+// Close closes the body and releases the request's slot
+func (b *politeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
@@ -0,0 +1,70 @@
+package browser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPolitenessSpacingAndConcurrency checks that requests to one host honor
+// the Crawl-delay and never exceed the concurrency limit
+func TestPolitenessSpacingAndConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("<html><body>ok</body></html>"))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	crawlDelay := func(ctx context.Context, u *url.URL, userAgent string) time.Duration {
+		return 200 * time.Millisecond
+	}
+	client := &http.Client{Transport: newPoliteTransport(PolitenessPolicy{
+		RequestsPerSecond:    100,
+		MaxConcurrentPerHost: 1,
+		RespectCrawlDelay:    true,
+		MaxDelay:             time.Second,
+	}, 10*time.Second, crawlDelay, http.DefaultTransport)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if len(starts) != 3 || maxInFlight != 1 {
+		t.Fatalf("expected 3 sequential requests, got %d with %d in flight", len(starts), maxInFlight)
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 200*time.Millisecond {
+			t.Fatalf("requests %d and %d only %v apart, want the 200ms Crawl-delay", i-1, i, gap)
+		}
+	}
+}
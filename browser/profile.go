@@ -0,0 +1,224 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the header profile used when none is selected
+const DefaultProfile = "desktop-chrome"
+
+// HeaderProfile describes one browser identity: the headers sent with every
+// request and what the JavaScript navigator, window and screen objects report.
+// Keeping both in one place stops sites from seeing two different browsers.
+type HeaderProfile struct {
+	Name           string
+	UserAgent      string
+	Headers        http.Header // Accept, Accept-Language, client hints and similar
+	Platform       string      // navigator.platform
+	Vendor         string      // navigator.vendor
+	Languages      []string    // navigator.languages, the first one is navigator.language
+	Brands         []string    // navigator.userAgentData brands as "name/version"
+	Mobile         bool
+	MaxTouchPoints int
+	WindowWidth    int
+	WindowHeight   int
+	ScreenWidth    int
+	ScreenHeight   int
+}
+
+// builtinProfiles returns the profiles that are always available
+func builtinProfiles() map[string]HeaderProfile {
+	chromeBrands := []string{"Not/A)Brand/8", "Chromium/126", "Google Chrome/126"}
+	return map[string]HeaderProfile{
+		"desktop-chrome": {
+			Name:      "desktop-chrome",
+			UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			Headers: http.Header{
+				"Accept":                    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
+				"Accept-Language":           {"en-US,en;q=0.9"},
+				"Sec-Ch-Ua":                 {clientHintBrands(chromeBrands)},
+				"Sec-Ch-Ua-Mobile":          {"?0"},
+				"Sec-Ch-Ua-Platform":        {`"macOS"`},
+				"Upgrade-Insecure-Requests": {"1"},
+			},
+			Platform:     "MacIntel",
+			Vendor:       "Google Inc.",
+			Languages:    []string{"en-US", "en"},
+			Brands:       chromeBrands,
+			WindowWidth:  1280,
+			WindowHeight: 800,
+			ScreenWidth:  1440,
+			ScreenHeight: 900,
+		},
+		"mobile-safari": {
+			Name:      "mobile-safari",
+			UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			Headers: http.Header{
+				"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+				"Accept-Language": {"en-US,en;q=0.9"},
+			},
+			Platform:       "iPhone",
+			Vendor:         "Apple Computer, Inc.",
+			Languages:      []string{"en-US", "en"},
+			Mobile:         true,
+			MaxTouchPoints: 5,
+			WindowWidth:    390,
+			WindowHeight:   664,
+			ScreenWidth:    390,
+			ScreenHeight:   844,
+		},
+		"honest-bot": {
+			Name:      "honest-bot",
+			UserAgent: "brauser/1.0 (terminal browser; +https://github.com/devskale/brauser)",
+			Headers: http.Header{
+				"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+				"Accept-Language": {"en"},
+			},
+			Languages:    []string{"en"},
+			WindowWidth:  1024,
+			WindowHeight: 768,
+			ScreenWidth:  1024,
+			ScreenHeight: 768,
+		},
+	}
+}
+
+// clientHintBrands formats brands as a Sec-CH-UA header value
+func clientHintBrands(brands []string) string {
+	parts := make([]string, 0, len(brands))
+	for _, brand := range brands {
+		name, version := brand, ""
+		if i := strings.LastIndex(brand, "/"); i >= 0 {
+			name, version = brand[:i], brand[i+1:]
+		}
+		parts = append(parts, fmt.Sprintf("%q;v=%q", name, version))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Clone returns a copy of the profile under a new name that can be changed
+// without affecting the original
+func (p HeaderProfile) Clone(name string) HeaderProfile {
+	p.Name = name
+	p.Headers = p.Headers.Clone()
+	if p.Headers == nil {
+		p.Headers = http.Header{}
+	}
+	p.Languages = append([]string(nil), p.Languages...)
+	p.Brands = append([]string(nil), p.Brands...)
+	return p
+}
+
+// hostProfile assigns a profile to a host, ".example.com" includes subdomains
+type hostProfile struct {
+	host    string
+	profile string
+}
+
+// AddProfile makes a custom profile available by its name, replacing a
+// profile with the same name
+func (c *Client) AddProfile(profile HeaderProfile) {
+	c.profiles[profile.Name] = profile
+}
+
+// LookupProfile returns the profile with the given name
+func (c *Client) LookupProfile(name string) (HeaderProfile, bool) {
+	profile, ok := c.profiles[strings.ToLower(name)]
+	return profile, ok
+}
+
+// ProfileNames returns the names of all available profiles, sorted
+func (c *Client) ProfileNames() []string {
+	names := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProfile selects the profile used for hosts without their own profile
+func (c *Client) SetProfile(name string) error {
+	profile, ok := c.LookupProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown header profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	c.profile = profile.Name
+	return nil
+}
+
+// SetHostProfile selects the profile used for one host. The most specific
+// host entry wins when several match.
+func (c *Client) SetHostProfile(host, name string) error {
+	profile, ok := c.LookupProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown header profile %q for %s", name, host)
+	}
+	for i := range c.hostProfiles {
+		if strings.EqualFold(c.hostProfiles[i].host, host) {
+			c.hostProfiles[i].profile = profile.Name
+			return nil
+		}
+	}
+	c.hostProfiles = append(c.hostProfiles, hostProfile{host: host, profile: profile.Name})
+	return nil
+}
+
+// SetHeader adds a header sent with every request, overriding the profile.
+// An empty value removes the header from requests.
+func (c *Client) SetHeader(name, value string) {
+	if c.headers == nil {
+		c.headers = http.Header{}
+	}
+	c.headers.Set(name, value)
+}
+
+// Profile returns the profile used for requests to the given host
+func (c *Client) Profile(host string) HeaderProfile {
+	name, bestLength := c.profile, -1
+	for _, entry := range c.hostProfiles {
+		if hostMatches(host, []string{entry.host}) && len(entry.host) > bestLength {
+			name, bestLength = entry.profile, len(entry.host)
+		}
+	}
+	return c.profiles[name]
+}
+
+// userAgent returns the User-Agent sent to the given host
+func (c *Client) userAgent(host string) string {
+	return c.Profile(host).UserAgent
+}
+
+// profileTransport adds the headers of the host's profile and the custom
+// headers to requests. Headers set on the request itself are kept.
+type profileTransport struct {
+	client *Client
+	next   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	profile := t.client.Profile(req.URL.Hostname())
+	clone := req.Clone(req.Context())
+	set := func(name, value string) {
+		if req.Header.Get(name) != "" {
+			return
+		}
+		if value == "" {
+			clone.Header.Del(name)
+		} else {
+			clone.Header.Set(name, value)
+		}
+	}
+	set("User-Agent", profile.UserAgent)
+	for name, values := range profile.Headers {
+		set(name, strings.Join(values, ", "))
+	}
+	for name, values := range t.client.headers {
+		set(name, strings.Join(values, ", "))
+	}
+	return t.next.RoundTrip(clone)
+}
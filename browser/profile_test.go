@@ -0,0 +1,58 @@
+package browser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestHeaderProfiles checks that requests carry the headers of the profile
+// selected for their host, with custom headers on top
+func TestHeaderProfiles(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("<html><body>Headers were received by the test server</body></html>"))
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+
+	client := NewClient()
+	fetch := func() {
+		t.Helper()
+		if _, err := client.Fetch(context.Background(), server.URL, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fetch()
+	if !strings.Contains(received.Get("User-Agent"), "Chrome/") || received.Get("Sec-Ch-Ua-Mobile") != "?0" || received.Get("Accept-Language") == "" {
+		t.Fatalf("default profile headers missing: %v", received)
+	}
+
+	if err := client.SetHostProfile(host.Hostname(), "mobile-safari"); err != nil {
+		t.Fatal(err)
+	}
+	client.SetHeader("DNT", "1")
+	fetch()
+	if !strings.Contains(received.Get("User-Agent"), "iPhone") || received.Get("Sec-Ch-Ua") != "" || received.Get("Dnt") != "1" {
+		t.Fatalf("host profile not applied: %v", received)
+	}
+	if profile := client.Profile("other.example"); profile.Name != DefaultProfile {
+		t.Fatalf("host profile leaked to other hosts: %s", profile.Name)
+	}
+
+	if err := client.SetProfile("no-such-profile"); err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+	client.SetUserAgent("custom-agent/2.0")
+	if profile := client.Profile("other.example"); profile.UserAgent != "custom-agent/2.0" || profile.Platform != "MacIntel" {
+		t.Fatalf("SetUserAgent lost the rest of the profile: %+v", profile)
+	}
+	if got := clientHintBrands([]string{"Chromium/126"}); got != `"Chromium";v="126"` {
+		t.Fatalf("clientHintBrands = %s", got)
+	}
+}
@@ -0,0 +1,330 @@
+package browser
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Article is the main content of a page as found by ExtractArticle
+type Article struct {
+	Title      string
+	Byline     string
+	Published  string // Publication date as given by the page, e.g. 2024-05-01T08:00:00Z
+	LeadImage  string // Absolute URL of the image that represents the article
+	SiteName   string
+	Excerpt    string
+	Content    []*html.Node // Top-level nodes of the article body, in document order
+	TextLength int
+}
+
+// Readability tuning; a page needs this much body text to count as an article
+const (
+	articleMinTextLength   = 250
+	paragraphMinTextLength = 25
+)
+
+var (
+	unlikelyCandidate = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumb|combx|comment|community|cookie|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|newsletter|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|ad-break|agegate|pagination|pager|popup|promo|yom-remote`)
+	maybeCandidate    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|story|post|entry`)
+	positiveHint      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeHint      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineHint        = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+)
+
+// ExtractArticle finds the article body of a page by scoring its elements on
+// the amount of text, commas, link density and class or id hints, and collects
+// the byline, publication date and lead image. It returns nil when no part of
+// the page looks like an article. The document is not modified.
+func ExtractArticle(doc *goquery.Document, baseURL string) *Article {
+	base, _ := url.Parse(baseURL)
+	article := &Article{
+		Title:     articleTitle(doc),
+		SiteName:  metaContent(doc, `meta[property="og:site_name"]`),
+		Excerpt:   metaContent(doc, `meta[property="og:description"]`, `meta[name="description"]`),
+		Byline:    metaContent(doc, `meta[name="author"]`, `meta[property="article:author"]`),
+		Published: metaContent(doc, `meta[property="article:published_time"]`, `meta[itemprop="datePublished"]`, `meta[name="date"]`, `meta[name="publish-date"]`, `meta[name="DC.date.issued"]`),
+		LeadImage: metaContent(doc, `meta[property="og:image"]`, `meta[name="twitter:image"]`),
+	}
+	if strings.HasPrefix(article.Byline, "http") {
+		article.Byline = "" // article:author is often a profile URL
+	}
+
+	work := goquery.CloneDocument(doc)
+	work.Find("script, style, noscript, template, iframe, svg, form, nav, aside, footer, dialog, [hidden], [aria-hidden=true]").Remove()
+	findArticleDetails(work, article)
+	removeUnlikelyCandidates(work)
+
+	top, scores := topCandidate(work)
+	if top == nil {
+		return nil
+	}
+	article.Content = articleNodes(top, scores)
+	for _, node := range article.Content {
+		article.TextLength += utf8.RuneCountInString(strings.TrimSpace(goquery.NewDocumentFromNode(node).Text()))
+	}
+	if article.TextLength < articleMinTextLength {
+		return nil
+	}
+
+	if article.LeadImage == "" {
+		for _, node := range article.Content {
+			if src := goquery.NewDocumentFromNode(node).Find("img[src]").First().AttrOr("src", ""); src != "" {
+				article.LeadImage = src
+				break
+			}
+		}
+	}
+	if article.LeadImage != "" && base != nil {
+		article.LeadImage = resolveAgainst(base, article.LeadImage)
+	}
+	return article
+}
+
+// articleTitle prefers the Open Graph title, then the document title, then the first h1
+func articleTitle(doc *goquery.Document) string {
+	if title := metaContent(doc, `meta[property="og:title"]`, `meta[name="twitter:title"]`); title != "" {
+		return title
+	}
+	if title := collapseSpace(doc.Find("title").First().Text()); title != "" {
+		return title
+	}
+	return collapseSpace(doc.Find("h1").First().Text())
+}
+
+// metaContent returns the content of the first matching meta element that has one
+func metaContent(doc *goquery.Document, selectors ...string) string {
+	for _, selector := range selectors {
+		if content := collapseSpace(doc.Find(selector).First().AttrOr("content", "")); content != "" {
+			return content
+		}
+	}
+	return ""
+}
+
+// findArticleDetails fills in the byline and date from the page body when the
+// meta tags did not have them, and removes the elements they came from so they
+// are not repeated in the body
+func findArticleDetails(doc *goquery.Document, article *Article) {
+	if article.Published == "" {
+		if published := doc.Find("time[datetime]").First(); published.Length() > 0 {
+			article.Published = published.AttrOr("datetime", "")
+			published.Remove()
+		} else if published := doc.Find(`[itemprop="datePublished"]`).First(); published.Length() > 0 {
+			article.Published = collapseSpace(published.AttrOr("content", published.Text()))
+		}
+	}
+
+	doc.Find("*").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "") + " " + s.AttrOr("rel", "") + " " + s.AttrOr("itemprop", "")
+		if !bylineHint.MatchString(hint) {
+			return true
+		}
+		text := collapseSpace(s.Text())
+		if text == "" || utf8.RuneCountInString(text) > 100 {
+			return true
+		}
+		if article.Byline == "" {
+			article.Byline = text
+		}
+		if strings.EqualFold(text, article.Byline) || strings.Contains(text, article.Byline) {
+			s.Remove()
+		}
+		return false
+	})
+}
+
+// removeUnlikelyCandidates drops elements whose class or id marks them as page
+// chrome, unless they also look like content
+func removeUnlikelyCandidates(doc *goquery.Document) {
+	doc.Find("body *").Each(func(i int, s *goquery.Selection) {
+		switch goquery.NodeName(s) {
+		case "body", "a", "article", "main", "table", "tbody", "tr", "td", "th":
+			return
+		}
+		hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "") + " " + s.AttrOr("role", "")
+		if strings.TrimSpace(hint) == "" {
+			return
+		}
+		if unlikelyCandidate.MatchString(hint) && !maybeCandidate.MatchString(hint) {
+			s.Remove()
+		}
+	})
+}
+
+// classWeight scores the class and id of an element: content hints count up,
+// hints for comments, sidebars and the like count down
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, attr := range []string{"class", "id"} {
+		value := nodeAttr(n, attr)
+		if value == "" {
+			continue
+		}
+		if negativeHint.MatchString(value) {
+			weight -= 25
+		}
+		if positiveHint.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// initialScore is the score an element starts with, based on its tag
+func initialScore(n *html.Node) float64 {
+	score := classWeight(n)
+	switch n.Data {
+	case "article":
+		score += 10
+	case "div", "main", "section":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// topCandidate scores the ancestors of every paragraph and returns the element
+// with the highest score after weighing it by its link density, together with
+// the scores of all candidates
+func topCandidate(doc *goquery.Document) (*html.Node, map[*html.Node]float64) {
+	scores := map[*html.Node]float64{}
+	var order []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+
+	doc.Find("p, pre, td, blockquote, li, div, section").Each(func(i int, s *goquery.Selection) {
+		// Containers only count as paragraphs when they hold text directly
+		if name := goquery.NodeName(s); name == "div" || name == "section" || name == "li" || name == "td" {
+			if s.ChildrenFiltered("p, div, section, article, table, ul, ol, pre, blockquote").Length() > 0 {
+				return
+			}
+		}
+		text := collapseSpace(s.Text())
+		length := utf8.RuneCountInString(text)
+		if length < paragraphMinTextLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length)/100, 3)
+
+		level := 0
+		for ancestor := s.Nodes[0].Parent; ancestor != nil && level < 3; ancestor = ancestor.Parent {
+			if ancestor.Type != html.ElementNode || ancestor.Data == "html" {
+				break
+			}
+			switch level {
+			case 0:
+				addScore(ancestor, score)
+			case 1:
+				addScore(ancestor, score/2)
+			default:
+				addScore(ancestor, score/float64(level*3))
+			}
+			level++
+		}
+	})
+
+	var top *html.Node
+	best := 0.0
+	for _, n := range order {
+		score := scores[n] * (1 - linkDensity(n))
+		scores[n] = score
+		if score > best {
+			top, best = n, score
+		}
+	}
+	if top == nil {
+		return nil, nil
+	}
+
+	// A parent that scores almost as well holds more of the article, as when
+	// the body is split into several sections
+	for parent := top.Parent; parent != nil && parent.Type == html.ElementNode && parent.Data != "body"; parent = parent.Parent {
+		if score, ok := scores[parent]; ok && score >= best*0.75 {
+			top, best = parent, score
+		}
+	}
+	scores[top] = best
+	return top, scores
+}
+
+// articleNodes returns the top candidate together with the siblings that
+// belong to the article: well scored ones and text paragraphs with few links
+func articleNodes(top *html.Node, scores map[*html.Node]float64) []*html.Node {
+	if top.Parent == nil || top.Data == "body" {
+		return []*html.Node{top}
+	}
+
+	threshold := math.Max(10, scores[top]*0.2)
+	topClass := nodeAttr(top, "class")
+	var nodes []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		if sibling == top {
+			nodes = append(nodes, sibling)
+			continue
+		}
+		bonus := 0.0
+		if topClass != "" && nodeAttr(sibling, "class") == topClass {
+			bonus = scores[top] * 0.2
+		}
+		if score, ok := scores[sibling]; ok && score+bonus >= threshold {
+			nodes = append(nodes, sibling)
+			continue
+		}
+		if sibling.Data == "p" {
+			text := collapseSpace(goquery.NewDocumentFromNode(sibling).Text())
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(sibling)
+			if length > 80 && density < 0.25 || length > 0 && length <= 80 && density == 0 && strings.Contains(text, ". ") {
+				nodes = append(nodes, sibling)
+			}
+		}
+	}
+	return nodes
+}
+
+// linkDensity is the share of an element's text that is inside links
+func linkDensity(n *html.Node) float64 {
+	s := goquery.NewDocumentFromNode(n)
+	total := utf8.RuneCountInString(collapseSpace(s.Text()))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		weight := 1.0
+		if strings.HasPrefix(a.AttrOr("href", ""), "#") {
+			weight = 0.3 // Links within the page, such as footnotes, are part of the text
+		}
+		linked += int(weight * float64(utf8.RuneCountInString(collapseSpace(a.Text()))))
+	})
+	return float64(linked) / float64(total)
+}
+
+// nodeAttr returns an attribute of an element node
+func nodeAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
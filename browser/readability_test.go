@@ -0,0 +1,77 @@
+package browser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const articlePage = `<html><head>
+<title>Tending a small garden | Weekend Notes</title>
+<meta property="og:site_name" content="Weekend Notes">
+</head><body>
+<nav class="menu"><a href="/">Home</a> <a href="/news">News</a> <a href="/garden">Garden</a></nav>
+<div id="main">
+  <div class="sidebar"><a href="/a">Popular one</a> <a href="/b">Popular two</a> <a href="/c">Popular three</a></div>
+  <article class="post">
+    <h1>Tending a small garden</h1>
+    <p class="byline">By Robin Example</p>
+    <time datetime="2024-05-01T08:00:00Z">May 1</time>
+    <img src="/img/beds.jpg" alt="Raised beds">
+    <p>A small garden rewards patience, regular watering, and a little planning before the first seeds go into the ground in spring.</p>
+    <p>Start with soil: loosen it, mix in compost, and check the drainage after heavy rain, because roots rot in standing water.</p>
+    <p>Choose plants that suit the light you have, water early in the morning, and keep a notebook of what grew well each year.</p>
+  </article>
+  <div class="comments"><p>Great post, thanks for sharing these tips, they really help a beginner like me!</p></div>
+</div>
+<footer>Copyright Weekend Notes</footer>
+</body></html>`
+
+// TestExtractArticle checks that the article body is found without the page
+// chrome around it, together with the byline, date and lead image
+func TestExtractArticle(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(articlePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article := ExtractArticle(doc, "https://notes.example/2024/garden")
+	if article == nil {
+		t.Fatal("expected an article")
+	}
+	if article.Byline != "By Robin Example" || article.Published != "2024-05-01T08:00:00Z" || article.SiteName != "Weekend Notes" {
+		t.Fatalf("unexpected details: %+v", article)
+	}
+	if article.LeadImage != "https://notes.example/img/beds.jpg" {
+		t.Fatalf("unexpected lead image %q", article.LeadImage)
+	}
+
+	var text strings.Builder
+	for _, node := range article.Content {
+		var buf strings.Builder
+		html.Render(&buf, node)
+		text.WriteString(buf.String())
+	}
+	body := text.String()
+	for _, want := range []string{"Start with soil", "keep a notebook"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("article body is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Popular one", "Great post", "Copyright", "News", "Robin Example"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("article body contains %q", unwanted)
+		}
+	}
+	if doc.Find(".sidebar").Length() != 1 {
+		t.Error("the document was modified")
+	}
+
+	links, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><ul>` +
+		strings.Repeat(`<li><a href="/x">A link with a long enough title to count as text here</a></li>`, 20) +
+		`</ul></body></html>`))
+	if article := ExtractArticle(links, "https://notes.example/"); article != nil {
+		t.Fatalf("expected no article on a page of links, got %d characters", article.TextLength)
+	}
+}
@@ -0,0 +1,254 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Kinds of redirects recorded in PageResult.RedirectChain
+const (
+	RedirectHTTP        = "http"
+	RedirectMetaRefresh = "meta-refresh"
+	RedirectJavaScript  = "javascript"
+)
+
+// Redirect is one hop of a redirect chain
+type Redirect struct {
+	From       string
+	To         string
+	StatusCode int    // HTTP status of the redirect response, 0 for client-side redirects
+	Kind       string // RedirectHTTP, RedirectMetaRefresh or RedirectJavaScript
+}
+
+// RedirectError is returned when HTTP redirects loop or exceed the limit
+type RedirectError struct {
+	URL   string
+	Chain []Redirect
+	Loop  bool // True if the chain returned to a URL it had visited twice
+}
+
+func (e *RedirectError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("redirect loop at %s", e.URL)
+	}
+	return fmt.Sprintf("stopped after %d redirects at %s", len(e.Chain), e.URL)
+}
+
+// redirectChainKey is the context key for the chain recorded by checkRedirect
+type redirectChainKey struct{}
+
+// redirectRecorder collects the HTTP redirects of a single request
+type redirectRecorder struct {
+	chain []Redirect
+}
+
+// withRedirectRecorder returns a context in which checkRedirect records the chain
+func withRedirectRecorder(ctx context.Context) (context.Context, *redirectRecorder) {
+	recorder := &redirectRecorder{}
+	return context.WithValue(ctx, redirectChainKey{}, recorder), recorder
+}
+
+// checkRedirect is the CheckRedirect hook of the HTTP client. It records each
+// hop and stops at loops and after maxRedirects hops.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	hop := Redirect{
+		From: via[len(via)-1].URL.String(),
+		To:   req.URL.String(),
+		Kind: RedirectHTTP,
+	}
+	if req.Response != nil {
+		hop.StatusCode = req.Response.StatusCode
+	}
+
+	var chain []Redirect
+	if recorder, ok := req.Context().Value(redirectChainKey{}).(*redirectRecorder); ok {
+		recorder.chain = append(recorder.chain, hop)
+		chain = recorder.chain
+	}
+
+	// A remote page must not be able to send the client to local files
+	if isLocalURL(hop.To) && !isLocalURL(hop.From) {
+		return &PolicyViolationError{URL: hop.To, Rule: "redirect to a local URL"}
+	}
+	// A→B→A is a common login flow; only a URL visited twice already is a loop
+	visits := 0
+	for _, previous := range via {
+		if previous.URL.String() == hop.To {
+			visits++
+		}
+	}
+	if visits > 1 {
+		return &RedirectError{URL: hop.To, Chain: chain, Loop: true}
+	}
+	if len(via) > c.maxRedirects {
+		return &RedirectError{URL: hop.To, Chain: chain}
+	}
+	return nil
+}
+
+// maxRefreshDelay is the longest meta refresh delay followed automatically;
+// slower refreshes are shown as a link instead
+const maxRefreshDelay = 5 * time.Second
+
+var (
+	// refreshURLPattern extracts the delay and target of a meta refresh content attribute
+	refreshURLPattern = regexp.MustCompile(`(?i)^\s*([\d.]*)\s*[;,]?\s*(?:url\s*=\s*)?(.*)$`)
+
+	// jsRedirectPatterns match simple location assignments and calls that start
+	// a statement, not properties such as geolocation or obj.location
+	jsRedirectPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?:^|[;\s{(])(?:(?:window|document|top|self)\.)?location\b(?:\.href)?\s*=\s*["']([^"']+)["']`),
+		regexp.MustCompile(`(?:^|[;\s{(])(?:(?:window|document|top|self)\.)?location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`),
+	}
+)
+
+// findClientRedirect looks for a meta refresh or a JavaScript location change
+// in an HTML page and returns the absolute target URL, the redirect kind and,
+// for a meta refresh, its delay. Refreshes that reload the page itself are ignored.
+func findClientRedirect(content, pageURL string) (string, string, time.Duration) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", "", 0
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", "", 0
+	}
+
+	resolve := func(target string) string {
+		target = strings.Trim(strings.TrimSpace(target), `"'`)
+		if target == "" {
+			return ""
+		}
+		ref, err := url.Parse(target)
+		if err != nil {
+			return ""
+		}
+		resolved := base.ResolveReference(ref)
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			return ""
+		}
+		resolved.Fragment = ""
+		if resolved.String() == pageURL {
+			return ""
+		}
+		return resolved.String()
+	}
+
+	var target, kind string
+	var delay time.Duration
+	doc.Find("meta[http-equiv]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(s.AttrOr("http-equiv", "")), "refresh") {
+			return true
+		}
+		if match := refreshURLPattern.FindStringSubmatch(s.AttrOr("content", "")); match != nil {
+			if target = resolve(match[2]); target != "" {
+				kind = RedirectMetaRefresh
+				if seconds, err := strconv.ParseFloat(match[1], 64); err == nil {
+					delay = time.Duration(seconds * float64(time.Second))
+				}
+				return false
+			}
+		}
+		return true
+	})
+	if target != "" {
+		return target, kind, delay
+	}
+
+	doc.Find("script:not([src])").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		script := topLevelScript(s.Text())
+		for _, pattern := range jsRedirectPatterns {
+			if match := pattern.FindStringSubmatch(script); match != nil {
+				if target = resolve(match[1]); target != "" {
+					kind = RedirectJavaScript
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return target, kind, 0
+}
+
+// refreshLink adds a link to the target of a slow meta refresh at the top of
+// the page body, so the reader can follow it without waiting
+func refreshLink(content, target string, delay time.Duration) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+	doc.Find("body").PrependHtml(fmt.Sprintf(`<p>This page refreshes to <a href="%s">%s</a> after %s.</p>`,
+		html.EscapeString(target), html.EscapeString(target), delay))
+	updated, err := doc.Html()
+	if err != nil {
+		return content
+	}
+	return updated
+}
+
+// topLevelScript returns the parts of a script outside braces, so location
+// changes in functions and event handlers, which may never run, are ignored.
+// Braces inside strings and comments are skipped.
+func topLevelScript(script string) string {
+	var top strings.Builder
+	depth := 0
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(script) {
+				if depth == 0 {
+					top.WriteString(script[i : i+2])
+				}
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+		case c == '/' && i+1 < len(script) && script[i+1] == '/':
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				return top.String()
+			}
+			i += end
+			c = '\n'
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				return top.String()
+			}
+			i += end + 3
+			c = ' '
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+			continue
+		case c == '}':
+			depth = max(depth-1, 0)
+			top.WriteByte(';')
+			continue
+		}
+		if depth == 0 {
+			top.WriteByte(c)
+		}
+	}
+	return top.String()
+}
+
+// isHTMLContentType reports whether a response may contain client-side redirects
+func isHTMLContentType(contentType string) bool {
+	return contentType == "" || strings.Contains(strings.ToLower(contentType), "html")
+}
@@ -0,0 +1,114 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRedirectChain checks that HTTP, meta refresh and JavaScript redirects are
+// followed and recorded, and that loops are stopped
+func TestRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/meta", http.StatusFound)
+		case "/meta":
+			w.Write([]byte(`<html><head><meta http-equiv="Refresh" content="0; URL='/js'"></head><body>Redirecting...</body></html>`))
+		case "/js":
+			w.Write([]byte(`<html><body><script>window.location.href = "final";</script></body></html>`))
+		case "/loop-a":
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="1;url=/loop-b"></head></html>`))
+		case "/loop-b":
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="1;url=/loop-a"></head></html>`))
+		case "/login":
+			if _, err := r.Cookie("session"); err != nil {
+				http.Redirect(w, r, "/sso", http.StatusFound)
+				return
+			}
+			w.Write([]byte("<html><body>" + strings.Repeat("<p>Signed in.</p>", 40) + "</body></html>"))
+		case "/sso":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1", Path: "/"})
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/slow":
+			w.Write([]byte(`<html><head><meta http-equiv="refresh" content="30; url=/final"></head><body><p>Slow page.</p></body></html>`))
+		case "/http-loop":
+			http.Redirect(w, r, "/http-loop", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte("<html><body>" + strings.Repeat("<p>Final page content.</p>", 40) + "</body></html>"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	result, err := client.Fetch(context.Background(), server.URL+"/start", FetchOptions{EnableRetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL != server.URL+"/start" || result.FinalURL != server.URL+"/final" {
+		t.Fatalf("unexpected URLs: %s -> %s", result.URL, result.FinalURL)
+	}
+	wantKinds := []string{RedirectHTTP, RedirectMetaRefresh, RedirectJavaScript}
+	if len(result.RedirectChain) != len(wantKinds) {
+		t.Fatalf("expected %d redirects, got %+v", len(wantKinds), result.RedirectChain)
+	}
+	for i, kind := range wantKinds {
+		if result.RedirectChain[i].Kind != kind {
+			t.Errorf("redirect %d: kind %q, want %q", i, result.RedirectChain[i].Kind, kind)
+		}
+	}
+	if result.RedirectChain[0].StatusCode != http.StatusFound {
+		t.Errorf("HTTP redirect status %d, want 302", result.RedirectChain[0].StatusCode)
+	}
+
+	result, err = client.Fetch(context.Background(), server.URL+"/loop-a", FetchOptions{})
+	if err != nil || result.FinalURL != server.URL+"/loop-b" || len(result.Warnings) != 1 {
+		t.Fatalf("expected the client-side loop to stop with a warning, got %+v, %v", result, err)
+	}
+
+	result, err = client.Fetch(context.Background(), server.URL+"/login", FetchOptions{})
+	if err != nil || result.FinalURL != server.URL+"/login" || len(result.RedirectChain) != 2 {
+		t.Fatalf("expected a redirect back to the first page to be followed, got %+v, %v", result, err)
+	}
+
+	result, err = client.Fetch(context.Background(), server.URL+"/slow", FetchOptions{})
+	if err != nil || result.FinalURL != server.URL+"/slow" || len(result.RedirectChain) != 0 {
+		t.Fatalf("expected a slow refresh not to be followed, got %+v, %v", result, err)
+	}
+	if !strings.Contains(result.Content, `<a href="`+server.URL+`/final">`) || !strings.Contains(result.Content, "Slow page.") {
+		t.Fatalf("expected a link to the refresh target, got %q", result.Content)
+	}
+
+	_, err = client.Fetch(context.Background(), server.URL+"/http-loop", FetchOptions{})
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) || !redirectErr.Loop {
+		t.Fatalf("expected a RedirectError loop, got %v", err)
+	}
+}
+
+// TestJavaScriptRedirects checks that only top-level location changes are
+// taken as redirects
+func TestJavaScriptRedirects(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`window.location.href = "/next";`, "https://example.com/next"},
+		{`var a = 1;location.replace('/next')`, "https://example.com/next"},
+		{`if (old) location = "/next";`, "https://example.com/next"},
+		{`var geolocation = "/next";`, ""},
+		{`obj.location = "/next";`, ""},
+		{`button.onclick = function() { window.location = "/next"; };`, ""},
+		{`document.addEventListener("click", () => { location.assign("/next") });`, ""},
+		{`var s = "{"; // }` + "\n" + `location.href = "/next";`, "https://example.com/next"},
+	}
+	for _, test := range tests {
+		got, _, _ := findClientRedirect("<html><body><script>"+test.script+"</script></body></html>", "https://example.com/page")
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.script, got, test.want)
+		}
+	}
+}
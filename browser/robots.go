@@ -0,0 +1,383 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsCacheTTL   = 24 * time.Hour   // How long a fetched robots.txt is trusted
+	robotsRetryTTL   = time.Minute      // How long a failed fetch is remembered before trying again
+	robotsMaxSize    = 500 * 1024       // RFC 9309 asks crawlers to parse at least 500 KiB
+	robotsFetchLimit = 10 * time.Second // Upper bound for fetching a single robots.txt
+	robotsRedirects  = 5                // Redirects followed when fetching robots.txt
+	robotsHeader     = "X-Brauser-Robots"
+)
+
+// RobotsMode selects what the client does with URLs disallowed by robots.txt
+type RobotsMode int
+
+const (
+	RobotsIgnore  RobotsMode = iota // Do not fetch robots.txt for access checks
+	RobotsWarn                      // Load disallowed pages but add a warning to the result
+	RobotsEnforce                   // Refuse disallowed URLs with a *RobotsDisallowedError
+)
+
+// ParseRobotsMode parses "ignore", "warn" or "enforce"
+func ParseRobotsMode(value string) (RobotsMode, error) {
+	switch strings.ToLower(value) {
+	case "ignore", "off":
+		return RobotsIgnore, nil
+	case "warn":
+		return RobotsWarn, nil
+	case "enforce", "on":
+		return RobotsEnforce, nil
+	}
+	return RobotsIgnore, fmt.Errorf("unknown robots mode %q (use ignore, warn or enforce)", value)
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsGroup holds the rules that apply to one set of user agents
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsFile is a parsed robots.txt
+type robotsFile struct {
+	groups      []*robotsGroup
+	disallowAll bool // Set when the server failed, as RFC 9309 requires
+}
+
+// parseRobots parses a robots.txt file. Unknown lines are ignored.
+func parseRobots(r io.Reader) *robotsFile {
+	file := &robotsFile{}
+	var group *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsMaxSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// Consecutive user-agent lines share one group
+			if !inAgents {
+				group = &robotsGroup{}
+				file.groups = append(file.groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		}
+		inAgents = false
+		if group == nil {
+			continue
+		}
+
+		switch key {
+		case "allow", "disallow":
+			if value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				group.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	return file
+}
+
+// group returns the group for the given User-Agent header: the group naming the
+// longest product token contained in it, or the * group when none matches
+func (f *robotsFile) group(userAgent string) *robotsGroup {
+	userAgent = strings.ToLower(userAgent)
+	var best, fallback *robotsGroup
+	bestLength := 0
+	for _, group := range f.groups {
+		for _, agent := range group.agents {
+			if agent == "*" {
+				if fallback == nil {
+					fallback = group
+				}
+				continue
+			}
+			if agent != "" && strings.Contains(userAgent, agent) && len(agent) > bestLength {
+				best = group
+				bestLength = len(agent)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+// crawlDelay returns the Crawl-delay that applies to the given User-Agent
+func (f *robotsFile) crawlDelay(userAgent string) time.Duration {
+	if group := f.group(userAgent); group != nil {
+		return group.crawlDelay
+	}
+	return 0
+}
+
+// allowed reports whether the given User-Agent may fetch the path, which
+// includes the query. The longest matching rule wins and Allow wins ties.
+func (f *robotsFile) allowed(userAgent, path string) bool {
+	if f.disallowAll {
+		return false
+	}
+	group := f.group(userAgent)
+	if group == nil {
+		return true
+	}
+
+	allowed, matchLength := true, -1
+	for _, rule := range group.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > matchLength || (len(rule.pattern) == matchLength && rule.allow) {
+			allowed, matchLength = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// matchRobotsPattern matches a path against a rule where * matches any
+// sequence of characters and a trailing $ anchors the end of the path
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	if len(parts) == 1 {
+		return !anchored || pos == len(path)
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+
+	last := parts[len(parts)-1]
+	if anchored {
+		return len(path)-pos >= len(last) && strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path[pos:], last)
+}
+
+// robotsPath returns the part of u that robots.txt rules are matched against
+func robotsPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
+
+// robotsEntry is a cached robots.txt, filled in once the fetch finished
+type robotsEntry struct {
+	ready   chan struct{}
+	file    *robotsFile
+	expires time.Time
+}
+
+// RobotsCache fetches robots.txt once per host and keeps it in memory
+type RobotsCache struct {
+	transport http.RoundTripper
+	mu        sync.Mutex
+	entries   map[string]*robotsEntry
+}
+
+// NewRobotsCache creates a robots.txt cache that fetches through the given transport
+func NewRobotsCache(transport http.RoundTripper) *RobotsCache {
+	return &RobotsCache{
+		transport: transport,
+		entries:   make(map[string]*robotsEntry),
+	}
+}
+
+// get returns the robots.txt for the host of u, fetching it on first use.
+// Concurrent callers for the same host wait for a single fetch; if the caller
+// doing the fetch gives up, the next one in line fetches again.
+func (rc *RobotsCache) get(ctx context.Context, u *url.URL, userAgent string) (*robotsFile, error) {
+	key := u.Scheme + "://" + u.Host
+
+	for {
+		rc.mu.Lock()
+		entry, ok := rc.entries[key]
+		if ok && entry.file != nil && time.Now().After(entry.expires) {
+			ok = false
+		}
+		if !ok {
+			entry = &robotsEntry{ready: make(chan struct{})}
+			rc.entries[key] = entry
+			rc.mu.Unlock()
+
+			file, expires := rc.fetch(ctx, key, userAgent)
+			rc.mu.Lock()
+			if ctx.Err() == nil {
+				entry.file, entry.expires = file, expires
+			} else if rc.entries[key] == entry {
+				// A fetch cut short by the caller says nothing about the host
+				delete(rc.entries, key)
+			}
+			rc.mu.Unlock()
+			close(entry.ready)
+
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return file, nil
+		}
+		rc.mu.Unlock()
+
+		select {
+		case <-entry.ready:
+			if entry.file != nil {
+				return entry.file, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// crawlDelay returns the Crawl-delay the robots.txt of the host of u sets for
+// the User-Agent, or 0 when it cannot be fetched
+func (rc *RobotsCache) crawlDelay(ctx context.Context, u *url.URL, userAgent string) time.Duration {
+	file, err := rc.get(ctx, u, userAgent)
+	if err != nil {
+		return 0
+	}
+	return file.crawlDelay(userAgent)
+}
+
+// Allowed reports whether robots.txt lets the given User-Agent fetch u
+func (rc *RobotsCache) Allowed(ctx context.Context, u *url.URL, userAgent string) (bool, error) {
+	if u.Path == "/robots.txt" || (u.Scheme != "http" && u.Scheme != "https") {
+		return true, nil
+	}
+	file, err := rc.get(ctx, u, userAgent)
+	if err != nil {
+		return false, err
+	}
+	return file.allowed(userAgent, robotsPath(u)), nil
+}
+
+// fetch downloads and parses robots.txt. A missing file allows everything, an
+// unreachable host or a server error disallows everything as RFC 9309 requires.
+// A file that is neither cached offline nor in a replayed archive is treated
+// as unavailable and allows everything. Failures are retried after robotsRetryTTL.
+func (rc *RobotsCache) fetch(ctx context.Context, origin, userAgent string) (*robotsFile, time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, robotsFetchLimit)
+	defer cancel()
+
+	failed := time.Now().Add(robotsRetryTTL)
+	location := origin + "/robots.txt"
+	var resp *http.Response
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+		if err != nil {
+			return &robotsFile{disallowAll: true}, failed
+		}
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+
+		resp, err = rc.transport.RoundTrip(req)
+		var notCached *NotCachedError
+		var miss *HARMissError
+		if errors.As(err, &notCached) || errors.As(err, &miss) {
+			return &robotsFile{}, failed
+		}
+		if err != nil {
+			return &robotsFile{disallowAll: true}, failed
+		}
+		next, err := resp.Location()
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil || redirects == robotsRedirects {
+			break
+		}
+		resp.Body.Close()
+		location = next.String()
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return &robotsFile{disallowAll: true}, failed
+	case resp.StatusCode >= 400:
+		return &robotsFile{}, time.Now().Add(robotsCacheTTL)
+	case resp.StatusCode != http.StatusOK:
+		return &robotsFile{}, failed
+	}
+
+	reader, err := decodeContentEncoding(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return &robotsFile{}, failed
+	}
+	defer reader.Close()
+	return parseRobots(reader), time.Now().Add(robotsCacheTTL)
+}
+
+// robotsTransport checks every request against robots.txt. Disallowed requests
+// fail with a *RobotsDisallowedError in RobotsEnforce mode; in RobotsWarn mode
+// the response is marked with the X-Brauser-Robots header instead.
+type robotsTransport struct {
+	robots *RobotsCache
+	mode   RobotsMode
+	next   http.RoundTripper
+}
+
+// RoundTrip checks the request URL before passing the request on
+func (t *robotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	userAgent := req.Header.Get("User-Agent")
+	allowed, err := t.robots.Allowed(req.Context(), req.URL, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed && t.mode == RobotsEnforce {
+		return nil, &RobotsDisallowedError{URL: req.URL.String(), UserAgent: userAgent}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && !allowed {
+		resp.Header.Set(robotsHeader, "disallowed")
+	}
+	return resp, err
+}
@@ -0,0 +1,266 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `# Test rules
+User-agent: brauserbot
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+Disallow: /*.pdf$
+Disallow: /search?*q=
+Crawl-delay: 2
+`
+
+// TestRobotsMatching checks group selection, wildcards, $ anchors and rule precedence
+func TestRobotsMatching(t *testing.T) {
+	file := parseRobots(strings.NewReader(testRobots))
+	browserUA := "Mozilla/5.0 Chrome/91.0"
+
+	tests := []struct {
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{browserUA, "/", true},
+		{browserUA, "/private/", false},
+		{browserUA, "/private/public", true},
+		{browserUA, "/private/public/more", false},
+		{browserUA, "/docs/manual.pdf", false},
+		{browserUA, "/docs/manual.pdf?download=1", true},
+		{browserUA, "/search?lang=en&q=go", false},
+		{browserUA, "/search", true},
+		{"BrauserBot/1.0", "/anything", false},
+	}
+	for _, tt := range tests {
+		if got := file.allowed(tt.userAgent, tt.path); got != tt.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tt.userAgent, tt.path, got, tt.want)
+		}
+	}
+	if delay := file.crawlDelay(browserUA); delay.Seconds() != 2 {
+		t.Errorf("crawl delay %v, want 2s", delay)
+	}
+}
+
+// TestRobotsModes checks that enforce mode refuses disallowed pages while
+// warn mode loads them with a warning
+func TestRobotsModes(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte(testRobots))
+			return
+		}
+		w.Write([]byte("<html><body>page</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetRobotsMode(RobotsEnforce)
+	_, err := client.Fetch(context.Background(), server.URL+"/private/secret", FetchOptions{})
+	var robotsErr *RobotsDisallowedError
+	if !errors.As(err, &robotsErr) || requests["/private/secret"] != 0 {
+		t.Fatalf("expected RobotsDisallowedError without a request, got %v", err)
+	}
+	if _, err := client.Fetch(context.Background(), server.URL+"/open", FetchOptions{}); err != nil {
+		t.Fatalf("allowed page failed: %v", err)
+	}
+
+	client.SetRobotsMode(RobotsWarn)
+	result, err := client.Fetch(context.Background(), server.URL+"/private/secret", FetchOptions{})
+	if err != nil || len(result.Warnings) != 1 {
+		t.Fatalf("expected the page with a warning, got %v, %v", result, err)
+	}
+	if requests["/robots.txt"] != 1 {
+		t.Fatalf("robots.txt fetched %d times, want 1", requests["/robots.txt"])
+	}
+}
+
+// TestRobotsCacheSharedFetch checks that concurrent callers share one fetch and
+// that a fetch given up by its caller is not remembered for the others
+func TestRobotsCacheSharedFetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(testRobots))
+	}))
+	defer server.Close()
+
+	robots := NewRobotsCache(server.Client().Transport)
+	page, _ := url.Parse(server.URL + "/private/secret")
+	if _, err := robots.Allowed(ctx, page, "testbot"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled fetch to fail, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, err := robots.Allowed(context.Background(), page, "testbot"); err != nil || allowed {
+				t.Errorf("expected the page to be disallowed, got %v, %v", allowed, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches.Load() != 2 {
+		t.Fatalf("robots.txt fetched %d times, want 2", fetches.Load())
+	}
+}
+
+// TestRobotsUnreachable checks that a host whose robots.txt cannot be fetched
+// is treated as disallowing everything
+func TestRobotsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	transport := server.Client().Transport
+	server.Close()
+
+	robots := NewRobotsCache(transport)
+	page, _ := url.Parse(server.URL + "/open")
+	if allowed, err := robots.Allowed(context.Background(), page, "testbot"); err != nil || allowed {
+		t.Fatalf("expected an unreachable host to disallow, got %v, %v", allowed, err)
+	}
+}
+
+// TestRobotsOfflineAndReplay checks that robots.txt is answered from the cache
+// and from HAR recordings, and that a robots.txt missing from either does not
+// block the pages that are there
+func TestRobotsOfflineAndReplay(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte("<html><body>" + r.URL.Path + "</body></html>"))
+	}))
+	cacheDir := t.TempDir()
+	harPath := filepath.Join(t.TempDir(), "session.har")
+
+	client := NewClient()
+	client.SetRobotsMode(RobotsEnforce)
+	if err := client.EnableCache(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	recorder := client.RecordHAR()
+	if _, err := client.Fetch(context.Background(), server.URL+"/page", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Save(harPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A page cached and recorded without robots.txt
+	unchecked := NewClient()
+	unchecked.SetRobotsMode(RobotsIgnore)
+	uncheckedDir := t.TempDir()
+	if err := unchecked.EnableCache(uncheckedDir); err != nil {
+		t.Fatal(err)
+	}
+	uncheckedRecorder := unchecked.RecordHAR()
+	if _, err := unchecked.Fetch(context.Background(), server.URL+"/page", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	uncheckedHAR := filepath.Join(t.TempDir(), "unchecked.har")
+	if err := uncheckedRecorder.Save(uncheckedHAR); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	har, err := LoadHAR(harPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 || !strings.HasSuffix(har.Log.Entries[0].Request.URL, "/robots.txt") {
+		t.Fatalf("expected robots.txt in the recording, got %d entries", len(har.Log.Entries))
+	}
+
+	check := func(name string, setup func(*Client) error, privateRefused bool) {
+		t.Helper()
+		client := NewClient()
+		client.SetRobotsMode(RobotsEnforce)
+		if err := setup(client); err != nil {
+			t.Fatal(err)
+		}
+		if result, err := client.Fetch(context.Background(), server.URL+"/page", FetchOptions{}); err != nil || len(result.Warnings) != 0 {
+			t.Errorf("%s: expected the page to load without warnings, got %v", name, err)
+		}
+		if !privateRefused {
+			return
+		}
+		var disallowed *RobotsDisallowedError
+		if _, err := client.Fetch(context.Background(), server.URL+"/private", FetchOptions{}); !errors.As(err, &disallowed) {
+			t.Errorf("%s: expected the recorded robots.txt to refuse /private, got %v", name, err)
+		}
+	}
+	offline := func(dir string) func(*Client) error {
+		return func(client *Client) error {
+			if err := client.EnableCache(dir); err != nil {
+				return err
+			}
+			return client.SetOfflineMode(true)
+		}
+	}
+	replay := func(path string) func(*Client) error {
+		return func(client *Client) error { return client.ReplayHAR(path) }
+	}
+	check("offline", offline(cacheDir), true)
+	check("replay", replay(harPath), true)
+	check("offline without robots.txt", offline(uncheckedDir), false)
+	check("replay without robots.txt", replay(uncheckedHAR), false)
+
+	if robotsFetches.Load() != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", robotsFetches.Load())
+	}
+}
+
+// TestRobotsCrawlDelay checks that polite clients wait the Crawl-delay from
+// the host's robots.txt between requests
+func TestRobotsCrawlDelay(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 0.2\n"))
+			return
+		}
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Write([]byte("<html><body>ok</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.EnablePoliteness(PolitenessPolicy{RequestsPerSecond: 100, RespectCrawlDelay: true, MaxDelay: time.Second})
+	for i := 0; i < 2; i++ {
+		if _, err := client.Fetch(context.Background(), server.URL+"/", FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(starts) != 2 || starts[1].Sub(starts[0]) < 200*time.Millisecond {
+		t.Fatalf("expected two requests 200ms apart, got %v", starts)
+	}
+}
@@ -0,0 +1,234 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NormalizeURL turns user input into a URL. http, https, file and data URLs
+// are kept, "-" stands for standard input, paths of local files become file
+// URLs and anything else is treated as a host name and gets https://.
+func NormalizeURL(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("empty URL")
+	}
+	if input == "-" {
+		return input, nil
+	}
+
+	lower := strings.ToLower(input)
+	for _, prefix := range []string{"http://", "https://", "file://", "data:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return input, nil
+		}
+	}
+
+	isPath := strings.HasPrefix(input, "/") || strings.HasPrefix(input, "./") ||
+		strings.HasPrefix(input, "../") || strings.HasPrefix(input, "~/")
+	if !isPath {
+		if _, err := os.Stat(input); err == nil {
+			isPath = true
+		}
+	}
+	if isPath {
+		return FileURL(input)
+	}
+	return "https://" + input, nil
+}
+
+// FileURL returns the file URL of a local path, expanding ~/ and making it absolute
+func FileURL(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find home directory: %v", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %v", path, err)
+	}
+	fileURL := &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if info, err := os.Stat(abs); err == nil && info.IsDir() && !strings.HasSuffix(fileURL.Path, "/") {
+		fileURL.Path += "/"
+	}
+	return fileURL.String(), nil
+}
+
+// isLocalURL reports whether a URL is served from local data instead of the network
+func isLocalURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+	return strings.HasPrefix(lower, "file:") || strings.HasPrefix(lower, "data:")
+}
+
+// CheckNavigation returns a *PolicyViolationError when a remote page links,
+// submits or navigates to a file: or data: URL; only local pages may do that
+func CheckNavigation(pageURL, target string) error {
+	if isLocalURL(target) && !isLocalURL(pageURL) {
+		return &PolicyViolationError{URL: target, Rule: "local URL from a remote page"}
+	}
+	return nil
+}
+
+// CheckEmbedded is CheckNavigation for images and other content embedded in
+// a page, where inline data: URLs are harmless but files are not
+func CheckEmbedded(pageURL, target string) error {
+	if strings.HasPrefix(strings.ToLower(target), "file:") && !isLocalURL(pageURL) {
+		return &PolicyViolationError{URL: target, Rule: "local file from a remote page"}
+	}
+	return nil
+}
+
+// localTransport serves file: and data: URLs and passes everything else on.
+// Local URLs bypass the cache, robots.txt and politeness layers but are
+// still checked against the URL policy.
+type localTransport struct {
+	files  http.RoundTripper
+	policy *URLPolicy
+	next   http.RoundTripper
+}
+
+// newLocalTransport creates a transport for file: and data: URLs in front of next
+func newLocalTransport(policy *URLPolicy, next http.RoundTripper) *localTransport {
+	return &localTransport{
+		files:  http.NewFileTransport(http.Dir("/")),
+		policy: policy,
+		next:   next,
+	}
+}
+
+// RoundTrip answers file: and data: requests locally
+func (t *localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	switch req.URL.Scheme {
+	case "file":
+		if err := t.policy.checkURL(req.URL); err != nil {
+			return nil, err
+		}
+		resp, err = t.files.RoundTrip(req)
+	case "data":
+		if err := t.policy.checkURL(req.URL); err != nil {
+			return nil, err
+		}
+		resp, err = dataResponse(req)
+	default:
+		return t.next.RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// dataResponse builds a response from a data: URL as defined in RFC 2397
+func dataResponse(req *http.Request) (*http.Response, error) {
+	raw := req.URL.String()
+	header, payload, found := strings.Cut(strings.TrimPrefix(raw[len("data"):], ":"), ",")
+	if !found {
+		return nil, fmt.Errorf("invalid data URL: missing comma")
+	}
+
+	mediaType := "text/plain;charset=US-ASCII"
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		isBase64 = true
+		header = header[:len(header)-len(";base64")]
+	}
+	if header != "" {
+		mediaType = header
+		if strings.HasPrefix(mediaType, ";") {
+			mediaType = "text/plain" + mediaType
+		}
+	}
+
+	var body []byte
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, mustUnescape(payload)))
+		if err != nil {
+			decoded, err = base64.RawStdEncoding.DecodeString(mustUnescape(payload))
+			if err != nil {
+				return nil, fmt.Errorf("invalid data URL: %v", err)
+			}
+		}
+		body = decoded
+	} else {
+		body = []byte(mustUnescape(payload))
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {mediaType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+// mustUnescape percent-decodes a data URL payload, keeping it as is if it is malformed
+func mustUnescape(value string) string {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// LoadDocument builds a page from r, such as HTML piped on standard input. It
+// goes through the same size limit, charset detection and content analysis as
+// Fetch; baseURL is used to resolve relative links and images.
+func (c *Client) LoadDocument(ctx context.Context, r io.Reader, baseURL, contentType string) (*PageResult, error) {
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	body, err := ReadLimited(r, c.maxDocumentSize, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	body, charset, err := decodeToUTF8(body, contentType)
+	if err != nil {
+		log.Printf("Charset conversion failed for %s: %v", baseURL, err)
+	}
+
+	result := &PageResult{
+		URL:         "-",
+		FinalURL:    baseURL,
+		StatusCode:  http.StatusOK,
+		Status:      "200 OK",
+		Header:      http.Header{"Content-Type": {contentType}},
+		ContentType: contentType,
+		Charset:     charset,
+		Content:     string(body),
+		Attempts:    []Attempt{{StatusCode: http.StatusOK, ContentLength: len(body), Duration: time.Since(start)}},
+		Duration:    time.Since(start),
+	}
+	if c.contentDetector != nil {
+		result.Analysis = c.contentDetector.AnalyzeContent(result.Content)
+		result.Analysis.Charset = charset
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLocalSources checks URL normalization and loading of file:, data: and
+// piped documents, and that remote pages cannot redirect to local files
+func TestLocalSources(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(page, []byte("<html><head><title>Local</title></head><body><a href=\"sub/next.html\">next</a></body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for input, want := range map[string]string{
+		"example.com":          "https://example.com",
+		"http://example.com/":  "http://example.com/",
+		"data:text/plain,hi":   "data:text/plain,hi",
+		"-":                    "-",
+		page:                   "file://" + filepath.ToSlash(page),
+		"file:///etc/hostname": "file:///etc/hostname",
+	} {
+		if got, err := NormalizeURL(input); err != nil || got != want {
+			t.Errorf("NormalizeURL(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	client := NewClient()
+	ctx := context.Background()
+	fileURL, _ := FileURL(page)
+	result, err := client.Fetch(ctx, fileURL, FetchOptions{EnableRetry: true})
+	if err != nil {
+		t.Fatalf("file URL failed: %v", err)
+	}
+	if !strings.Contains(result.Content, "<title>Local</title>") || !isHTMLContentType(result.ContentType) || result.Retries != 0 {
+		t.Errorf("unexpected file result: %s, %d retries", result.ContentType, result.Retries)
+	}
+
+	result, err = client.Fetch(ctx, "data:text/html;base64,PHA+aGk8L3A+", FetchOptions{})
+	if err != nil || result.Content != "<p>hi</p>" || result.ContentType != "text/html" {
+		t.Errorf("data URL = %+v, %v", result, err)
+	}
+
+	result, err = client.LoadDocument(ctx, strings.NewReader("<html><body>piped</body></html>"), fileURL, "")
+	if err != nil || result.FinalURL != fileURL || !isHTMLContentType(result.ContentType) {
+		t.Errorf("LoadDocument = %+v, %v", result, err)
+	}
+	result, err = client.LoadDocument(ctx, strings.NewReader("<p>caf\xe9</p>"), fileURL, "text/html; charset=iso-8859-1")
+	if err != nil || result.Content != "<p>café</p>" || result.Analysis == nil || result.Analysis.Charset != "windows-1252" {
+		t.Errorf("LoadDocument of a latin1 page = %+v, %v", result, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, fileURL, http.StatusFound)
+	}))
+	defer server.Close()
+	var violation *PolicyViolationError
+	if _, err := client.Fetch(ctx, server.URL, FetchOptions{}); !errors.As(err, &violation) {
+		t.Errorf("expected the redirect to a file to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Addresses of cloud metadata services, blocked even where a range is allow-listed
+var metadataAddresses = []netip.Addr{
+	netip.MustParseAddr("169.254.169.254"), // AWS, GCP, Azure, OpenStack
+	netip.MustParseAddr("169.254.170.2"),   // AWS ECS task metadata
+	netip.MustParseAddr("100.100.100.200"), // Alibaba Cloud
+	netip.MustParseAddr("fd00:ec2::254"),   // AWS over IPv6
+}
+
+// Special-purpose ranges that netip has no predicate for
+var (
+	thisNetwork  = netip.MustParsePrefix("0.0.0.0/8")
+	sharedSpace  = netip.MustParsePrefix("100.64.0.0/10")
+	benchmarking = netip.MustParsePrefix("198.18.0.0/15")
+)
+
+// BlockedAddressError is returned when the SSRF guard refuses to connect to a host
+type BlockedAddressError struct {
+	Host   string
+	IP     string
+	Reason string // "loopback", "private", "link-local", "cloud metadata", ...
+}
+
+func (e *BlockedAddressError) Error() string {
+	if e.IP == e.Host {
+		return fmt.Sprintf("refusing to connect to %s: %s address", e.Host, e.Reason)
+	}
+	return fmt.Sprintf("refusing to connect to %s (%s): %s address", e.Host, e.IP, e.Reason)
+}
+
+// SSRFGuard keeps the client away from loopback, private, link-local and
+// metadata addresses. Host names are resolved once and the connection is made
+// to the checked address, so a second DNS answer cannot rebind the name.
+type SSRFGuard struct {
+	allowedNets   []netip.Prefix
+	allowedHosts  []string
+	metadataAllow bool
+	lookup        func(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// NewSSRFGuard creates a guard. Entries in allow may be IP addresses, CIDR
+// ranges or host names (".example.com" includes subdomains); they may be
+// reached even if they are internal. Metadata addresses are only reachable
+// when listed exactly.
+func NewSSRFGuard(allow []string) (*SSRFGuard, error) {
+	guard := &SSRFGuard{lookup: net.DefaultResolver.LookupNetIP}
+	for _, entry := range allow {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			guard.allowedNets = append(guard.allowedNets, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			guard.allowedNets = append(guard.allowedNets, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			if isMetadataAddress(addr) {
+				guard.metadataAllow = true
+			}
+		} else if strings.ContainsAny(entry, "/:") {
+			return nil, fmt.Errorf("invalid SSRF allow-list entry %q", entry)
+		} else {
+			guard.allowedHosts = append(guard.allowedHosts, entry)
+		}
+	}
+	return guard, nil
+}
+
+// blockedReason returns why an address is internal, or "" for public addresses
+func blockedReason(addr netip.Addr) string {
+	addr = addr.Unmap()
+	switch {
+	case isMetadataAddress(addr):
+		return "cloud metadata"
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsPrivate():
+		return "private"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return "link-local"
+	case addr.IsUnspecified(), thisNetwork.Contains(addr):
+		return "unspecified"
+	case addr.IsMulticast(), addr.IsInterfaceLocalMulticast():
+		return "multicast"
+	case sharedSpace.Contains(addr), benchmarking.Contains(addr):
+		return "reserved"
+	}
+	return ""
+}
+
+// isMetadataAddress reports whether addr belongs to a cloud metadata service
+func isMetadataAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, metadata := range metadataAddresses {
+		if addr == metadata {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMetadata returns a *BlockedAddressError if addr is a metadata address
+// that is not listed exactly; it is all that is checked for allowed host names
+func (g *SSRFGuard) checkMetadata(host string, addr netip.Addr) error {
+	if isMetadataAddress(addr) && !g.metadataAllow {
+		return &BlockedAddressError{Host: host, IP: addr.Unmap().String(), Reason: "cloud metadata"}
+	}
+	return nil
+}
+
+// check returns a *BlockedAddressError if addr may not be contacted
+func (g *SSRFGuard) check(host string, addr netip.Addr) error {
+	reason := blockedReason(addr)
+	if reason == "" {
+		return nil
+	}
+	if reason == "cloud metadata" && !g.metadataAllow {
+		return &BlockedAddressError{Host: host, IP: addr.Unmap().String(), Reason: reason}
+	}
+	for _, prefix := range g.allowedNets {
+		if prefix.Contains(addr.Unmap()) {
+			return nil
+		}
+	}
+	return &BlockedAddressError{Host: host, IP: addr.Unmap().String(), Reason: reason}
+}
+
+// resolve returns the addresses of host, which may be an IP literal
+func (g *SSRFGuard) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	return g.lookup(ctx, "ip", host)
+}
+
+// CheckHost resolves host and fails if any of its addresses is blocked
+func (g *SSRFGuard) CheckHost(ctx context.Context, host string) error {
+	check := g.check
+	if hostMatches(host, g.allowedHosts) {
+		check = g.checkMetadata
+	}
+	addrs, err := g.resolve(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := check(host, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wrapDial returns a DialContext function that only connects to permitted addresses.
+// trusted host:port addresses, such as the configured proxies, are dialed without
+// checks; other ports on the same host are not.
+func (g *SSRFGuard) wrapDial(dial func(ctx context.Context, network, address string) (net.Conn, error), trusted func() []string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		for _, proxy := range trusted() {
+			if strings.EqualFold(net.JoinHostPort(host, port), proxy) {
+				return dial(ctx, network, address)
+			}
+		}
+		check := g.check
+		if hostMatches(host, g.allowedHosts) {
+			check = g.checkMetadata
+		}
+
+		addrs, err := g.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		// Connect to the first permitted address; blocked ones are skipped
+		var firstErr error
+		for _, addr := range addrs {
+			if err := check(host, addr); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no addresses found for %s", host)
+		}
+		return nil, firstErr
+	}
+}
+
+// ssrfProxyTransport checks the target of requests sent through a proxy,
+// since the proxy and not the guarded dialer connects to it
+type ssrfProxyTransport struct {
+	guard *SSRFGuard
+	proxy *proxyConfig
+	next  http.RoundTripper
+}
+
+// RoundTrip resolves and checks the target host when a proxy is used
+func (t *ssrfProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if proxyURL, err := t.proxy.transportProxy(req); err == nil && proxyURL != nil {
+		if err := t.guard.CheckHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// EnableSSRFGuard refuses connections to loopback, private, link-local and cloud
+// metadata addresses except those in allow, for pages, redirects, images and
+// every other request made through this client
+func (c *Client) EnableSSRFGuard(allow []string) error {
+	guard, err := NewSSRFGuard(allow)
+	if err != nil {
+		return err
+	}
+	c.ssrfGuard = guard
+	c.applySSRFGuard()
+	c.rebuildTransport()
+	return nil
+}
+
+// applySSRFGuard installs the guarded dialer on the base transports
+func (c *Client) applySSRFGuard() {
+	if c.ssrfGuard == nil {
+		return
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	trusted := func() []string { return c.proxy.addresses() }
+	c.baseTransport.DialContext = c.ssrfGuard.wrapDial(dialer.DialContext, trusted)
+	c.baseTransport.CloseIdleConnections()
+	if c.insecureTransport != nil {
+		c.insecureTransport.DialContext = c.baseTransport.DialContext
+		c.insecureTransport.CloseIdleConnections()
+	}
+}
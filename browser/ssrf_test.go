@@ -0,0 +1,97 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestSSRFGuard checks that internal addresses are refused unless allow-listed,
+// including after a redirect, and that metadata addresses stay blocked
+func TestSSRFGuard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://127.0.0.1"+r.Host[strings.LastIndex(r.Host, ":"):]+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html><body>internal</body></html>"))
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+
+	fetch := func(allow []string, url string) error {
+		client := NewClient()
+		if err := client.EnableSSRFGuard(allow); err != nil {
+			t.Fatal(err)
+		}
+		_, err := client.Fetch(context.Background(), url, FetchOptions{})
+		return err
+	}
+
+	var blocked *BlockedAddressError
+	if err := fetch(nil, server.URL); !errors.As(err, &blocked) || blocked.Reason != "loopback" || IsRetryable(err) {
+		t.Fatalf("expected a blocked loopback address, got %v", err)
+	}
+	if err := fetch([]string{"127.0.0.0/8"}, server.URL); err != nil {
+		t.Fatalf("allow-listed range was blocked: %v", err)
+	}
+	if err := fetch([]string{"localhost"}, "http://localhost"+port+"/"); err != nil {
+		t.Fatalf("allow-listed host was blocked: %v", err)
+	}
+	if err := fetch([]string{"localhost"}, "http://localhost"+port+"/redirect"); !errors.As(err, &blocked) {
+		t.Fatalf("expected the redirect target to be blocked, got %v", err)
+	}
+
+	guard, _ := NewSSRFGuard([]string{"169.254.0.0/16", "10.0.0.0/8"})
+	if err := guard.check("metadata", netip.MustParseAddr("169.254.169.254")); err == nil {
+		t.Fatal("metadata address allowed by a range")
+	}
+	for addr, want := range map[string]string{"10.1.2.3": "", "192.168.1.1": "private", "::ffff:127.0.0.1": "loopback", "fe80::1": "link-local", "8.8.8.8": ""} {
+		err := guard.check(addr, netip.MustParseAddr(addr))
+		if (want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), want)) {
+			t.Errorf("check(%s) = %v, want %q", addr, err, want)
+		}
+	}
+}
+
+// TestSSRFGuardTrustedAddresses checks that a trusted proxy only opens its own
+// port and that allowed host names cannot reach metadata addresses
+func TestSSRFGuardTrustedAddresses(t *testing.T) {
+	guard, _ := NewSSRFGuard([]string{"metadata.internal"})
+	guard.lookup = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("169.254.169.254")}, nil
+	}
+	var dialed []string
+	dial := guard.wrapDial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, errors.New("not connecting in tests")
+	}, func() []string { return []string{"127.0.0.1:3128"} })
+
+	var blocked *BlockedAddressError
+	dial(context.Background(), "tcp", "127.0.0.1:3128")
+	if _, err := dial(context.Background(), "tcp", "127.0.0.1:6379"); !errors.As(err, &blocked) || blocked.Reason != "loopback" {
+		t.Fatalf("expected another port of the proxy host to be blocked, got %v", err)
+	}
+	if _, err := dial(context.Background(), "tcp", "metadata.internal:80"); !errors.As(err, &blocked) || blocked.Reason != "cloud metadata" {
+		t.Fatalf("expected an allowed host resolving to a metadata address to be blocked, got %v", err)
+	}
+	if err := guard.CheckHost(context.Background(), "metadata.internal"); !errors.As(err, &blocked) {
+		t.Fatalf("expected CheckHost to block the metadata address, got %v", err)
+	}
+	if strings.Join(dialed, " ") != "127.0.0.1:3128" {
+		t.Fatalf("unexpected connections: %v", dialed)
+	}
+
+	guard, _ = NewSSRFGuard([]string{"metadata.internal", "169.254.169.254"})
+	guard.lookup = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("169.254.169.254")}, nil
+	}
+	if err := guard.CheckHost(context.Background(), "metadata.internal"); err != nil {
+		t.Fatalf("expected the exactly listed metadata address to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BrowserConfig holds the settings read from the brauser config file
+type BrowserConfig struct {
+	Network   NetworkConfig   `json:"network"`
+	Policy    PolicyConfig    `json:"policy"`
+	Downloads DownloadsConfig `json:"downloads"`
+	Auth      []AuthConfig    `json:"auth"`
+	Headers   HeadersConfig   `json:"headers"`
+}
+
+// NetworkConfig holds proxy and TLS settings for the HTTP client
+type NetworkConfig struct {
+	Proxy         string   `json:"proxy"`                      // http://, https:// or socks5:// URL, optionally with user:password
+	NoProxy       string   `json:"no_proxy"`                   // Comma-separated hosts, domains and CIDR ranges that bypass the proxy
+	CACerts       []string `json:"ca_certs"`                   // PEM files with additional root certificates
+	ClientCert    string   `json:"client_cert"`                // PEM certificate for mutual TLS
+	ClientKey     string   `json:"client_key"`                 // PEM private key for the client certificate
+	MinTLSVersion string   `json:"min_tls_version"`            // "1.0", "1.1", "1.2" or "1.3"
+	InsecureHosts []string `json:"insecure_skip_verify_hosts"` // Hosts whose certificates are not verified
+	BlockPrivate  bool     `json:"block_private"`              // Refuse loopback, private, link-local and metadata addresses
+	AllowPrivate  []string `json:"allow_private"`              // IPs, CIDR ranges and hosts exempt from block_private
+}
+
+// PolicyConfig lists the URLs that may and may not be visited. Deny rules win;
+// with allow rules present, every URL must match one of them.
+type PolicyConfig struct {
+	Allow []URLRuleConfig `json:"allow"`
+	Deny  []URLRuleConfig `json:"deny"`
+}
+
+// URLRuleConfig matches URLs; all fields that are set must match
+type URLRuleConfig struct {
+	Scheme     string `json:"scheme"`      // "http" or "https"
+	Host       string `json:"host"`        // Glob such as "*.example.com"
+	PathPrefix string `json:"path_prefix"` // Path and everything below it
+	Regex      string `json:"regex"`       // Regular expression for the whole URL
+}
+
+// DownloadsConfig sets where downloaded files are saved
+type DownloadsConfig struct {
+	Dir string `json:"dir"` // Download directory, ~/Downloads by default
+}
+
+// AuthConfig holds the credentials for one host. Secrets can be read from
+// environment variables instead of being stored in the file.
+type AuthConfig struct {
+	Host        string `json:"host"`         // Host name, ".example.com" includes subdomains
+	Username    string `json:"username"`     // Basic or Digest user name
+	Password    string `json:"password"`     // Basic or Digest password
+	PasswordEnv string `json:"password_env"` // Environment variable holding the password
+	Token       string `json:"token"`        // Bearer token
+	TokenEnv    string `json:"token_env"`    // Environment variable holding the bearer token
+	Insecure    bool   `json:"insecure"`     // Also send the credentials over plain http
+}
+
+// HeadersConfig selects the header profiles and adds custom request headers
+type HeadersConfig struct {
+	Profile  string                   `json:"profile"`  // Profile for the session: desktop-chrome, mobile-safari, honest-bot or a custom one
+	Hosts    map[string]string        `json:"hosts"`    // Profile per host, ".example.com" includes subdomains
+	Extra    map[string]string        `json:"extra"`    // Headers sent with every request, overriding the profile
+	Profiles map[string]ProfileConfig `json:"profiles"` // Custom profiles by name
+}
+
+// ProfileConfig defines a custom profile on top of a built-in one
+type ProfileConfig struct {
+	Extends      string            `json:"extends"`    // Built-in profile to start from, desktop-chrome by default
+	UserAgent    string            `json:"user_agent"` // Replaces the User-Agent header and navigator.userAgent
+	Headers      map[string]string `json:"headers"`    // Added to or replacing the headers of the base profile
+	Platform     string            `json:"platform"`   // navigator.platform
+	WindowWidth  int               `json:"window_width"`
+	WindowHeight int               `json:"window_height"`
+}
+
+// DefaultBrowserConfigPath returns ~/.brauser/config.json
+func DefaultBrowserConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %v", err)
+	}
+	return filepath.Join(home, ".brauser", "config.json"), nil
+}
+
+// LoadBrowserConfig loads the browser configuration from file
+func LoadBrowserConfig(configPath string) (*BrowserConfig, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config BrowserConfig
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON: %v", err)
+	}
+
+	return &config, nil
+}
@@ -5,7 +5,9 @@ go 1.24.5
 require (
 	github.com/PuerkitoBio/goquery v1.10.3
 	github.com/TheZoraiz/ascii-image-converter v1.13.1
+	github.com/andybalholm/brotli v1.1.1
 	github.com/dop251/goja v0.0.0-20250630131328-58d95d85e994
+	golang.org/x/net v0.39.0
 )
 
 require (
@@ -22,7 +24,6 @@ require (
 	github.com/nathan-fiscaletti/consolesize-go v0.0.0-20210105204122-a87d9f614b9d // indirect
 	github.com/xo/terminfo v0.0.0-20210125001918-ca9a967f8778 // indirect
 	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d // indirect
-	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 )
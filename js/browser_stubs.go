@@ -1,15 +1,55 @@
 package js
 
-import "github.com/dop251/goja"
+import (
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Fingerprint describes the browser that the navigator, window and screen
+// objects report. It should match the headers sent for the page.
+type Fingerprint struct {
+	UserAgent      string
+	Platform       string
+	Vendor         string
+	Languages      []string
+	Brands         []string // "name/version" pairs for navigator.userAgentData
+	Mobile         bool
+	MaxTouchPoints int
+	WindowWidth    int
+	WindowHeight   int
+	ScreenWidth    int
+	ScreenHeight   int
+}
+
+// withDefaults fills in what the fingerprint leaves open, using the user agent
+// from the JS config and a 1024x768 window
+func (f Fingerprint) withDefaults(userAgent string) Fingerprint {
+	if f.UserAgent == "" {
+		f.UserAgent = userAgent
+	}
+	if len(f.Languages) == 0 {
+		f.Languages = []string{"en-US"}
+	}
+	if f.WindowWidth == 0 || f.WindowHeight == 0 {
+		f.WindowWidth, f.WindowHeight = 1024, 768
+	}
+	if f.ScreenWidth == 0 || f.ScreenHeight == 0 {
+		f.ScreenWidth, f.ScreenHeight = f.WindowWidth, f.WindowHeight
+	}
+	return f
+}
 
 // setupBrowserStubs creates window, navigator, and location objects
 func (env *JSEnvironment) setupBrowserStubs() {
+	fingerprint := env.fingerprint.withDefaults(env.config.JavaScriptCompatibility.Categories.Browser.Navigator.UserAgent)
+	
 	// Window object
 	windowObj := env.vm.NewObject()
-	windowObj.Set("innerWidth", 1024)
-	windowObj.Set("innerHeight", 768)
-	windowObj.Set("outerWidth", 1024)
-	windowObj.Set("outerHeight", 768)
+	windowObj.Set("innerWidth", fingerprint.WindowWidth)
+	windowObj.Set("innerHeight", fingerprint.WindowHeight)
+	windowObj.Set("outerWidth", fingerprint.WindowWidth)
+	windowObj.Set("outerHeight", fingerprint.WindowHeight)
 	windowObj.Set("addEventListener", func(event string, handler interface{}) {})
 	windowObj.Set("removeEventListener", func(event string, handler interface{}) {})
 	windowObj.Set("setTimeout", func(callback interface{}, delay int) int { return 1 })
@@ -18,13 +58,44 @@ func (env *JSEnvironment) setupBrowserStubs() {
 	windowObj.Set("clearInterval", func(id int) {})
 	env.vm.Set("window", windowObj)
 	
+	// Screen object
+	screenObj := env.vm.NewObject()
+	screenObj.Set("width", fingerprint.ScreenWidth)
+	screenObj.Set("height", fingerprint.ScreenHeight)
+	screenObj.Set("availWidth", fingerprint.ScreenWidth)
+	screenObj.Set("availHeight", fingerprint.ScreenHeight)
+	screenObj.Set("colorDepth", 24)
+	windowObj.Set("screen", screenObj)
+	env.vm.Set("screen", screenObj)
+	
 	// Navigator object
 	navigatorObj := env.vm.NewObject()
-	navigatorObj.Set("userAgent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.114 Safari/537.36")
-	navigatorObj.Set("platform", "MacIntel")
-	navigatorObj.Set("language", "en-US")
+	navigatorObj.Set("userAgent", fingerprint.UserAgent)
+	navigatorObj.Set("appVersion", strings.TrimPrefix(fingerprint.UserAgent, "Mozilla/"))
+	navigatorObj.Set("platform", fingerprint.Platform)
+	navigatorObj.Set("vendor", fingerprint.Vendor)
+	navigatorObj.Set("language", fingerprint.Languages[0])
+	navigatorObj.Set("languages", fingerprint.Languages)
+	navigatorObj.Set("maxTouchPoints", fingerprint.MaxTouchPoints)
 	navigatorObj.Set("cookieEnabled", true)
 	navigatorObj.Set("onLine", true)
+	navigatorObj.Set("webdriver", false)
+	if len(fingerprint.Brands) > 0 {
+		brands := make([]interface{}, 0, len(fingerprint.Brands))
+		for _, brand := range fingerprint.Brands {
+			name, version := brand, ""
+			if i := strings.LastIndex(brand, "/"); i >= 0 {
+				name, version = brand[:i], brand[i+1:]
+			}
+			brands = append(brands, map[string]interface{}{"brand": name, "version": version})
+		}
+		userAgentData := env.vm.NewObject()
+		userAgentData.Set("brands", brands)
+		userAgentData.Set("mobile", fingerprint.Mobile)
+		userAgentData.Set("platform", fingerprint.Platform)
+		navigatorObj.Set("userAgentData", userAgentData)
+	}
+	windowObj.Set("navigator", navigatorObj)
 	env.vm.Set("navigator", navigatorObj)
 	
 	// Location object
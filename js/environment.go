@@ -12,8 +12,9 @@ import (
 
 // JSEnvironment manages the JavaScript runtime and stubs
 type JSEnvironment struct {
-	vm     *goja.Runtime
-	config *config.JSConfig
+	vm          *goja.Runtime
+	config      *config.JSConfig
+	fingerprint Fingerprint
 }
 
 // NewJSEnvironment creates a new JavaScript environment with the given configuration
@@ -24,6 +25,12 @@ func NewJSEnvironment(jsConfig *config.JSConfig) *JSEnvironment {
 	}
 }
 
+// SetFingerprint sets the browser identity reported by the browser stubs. It
+// must be called before SetupAllStubs.
+func (env *JSEnvironment) SetFingerprint(fingerprint Fingerprint) {
+	env.fingerprint = fingerprint
+}
+
 // SetupAllStubs sets up all JavaScript stubs based on the configuration
 func (env *JSEnvironment) SetupAllStubs() {
 	if env.config.JavaScriptCompatibility.Categories.Console.Enabled {
@@ -8,8 +8,9 @@ import (
 	"brauser/config"
 )
 
-// ExecuteJS processes and executes JavaScript from HTML document
-func ExecuteJS(doc *goquery.Document, title string) {
+// ExecuteJS processes and executes JavaScript from HTML document, pretending to
+// be the browser described by the fingerprint
+func ExecuteJS(doc *goquery.Document, title string, fingerprint Fingerprint) {
 	// Load JavaScript configuration
 	jsConfig, err := config.LoadJSConfig("js_config.json")
 	if err != nil {
@@ -41,6 +42,7 @@ func ExecuteJS(doc *goquery.Document, title string) {
 
 		// Create a new JavaScript environment for each script
 		env := NewJSEnvironment(jsConfig)
+		env.SetFingerprint(fingerprint)
 		env.SetupAllStubs()
 
 		// Set document title in the JavaScript environment
@@ -1,55 +1,661 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"net/http"
+	"net/url"
+	"os/signal"
 	"strings"
+	"time"
 
 	"brauser/browser"
+	"brauser/config"
 	"brauser/js"
 	"brauser/navigation"
 	"brauser/renderer"
-	"github.com/PuerkitoBio/goquery"
 )
 
+// options holds the settings parsed from the command line
+type options struct {
+	url          string
+	enableRetry  bool
+	session      string
+	cookieFile   string
+	cacheDir     string
+	offline      bool
+	harRecord    string
+	harReplay    string
+	politeness   *browser.PolitenessPolicy
+	robotsMode   browser.RobotsMode
+	config       *config.BrowserConfig
+	network      browser.NetworkOptions
+	maxPageSize  int64
+	maxImageSize int64
+	saveDir      string
+	blockPrivate bool
+	allowPrivate []string
+	urlPolicy    *browser.URLPolicy
+	baseURL      string
+	stdin        []byte
+	downloadList string
+	credentials  *browser.CredentialStore
+	headers      config.HeadersConfig
+	reader       bool
+}
+
+// parseOptions parses the command line, accepting flags before or after the URL
+func parseOptions(args []string) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("brauser", flag.ContinueOnError)
+	noRetry := flags.Bool("no-retry", false, "Disable content detection and retry logic")
+	flags.StringVar(&opts.session, "session", "", "Keep cookies in a named session under ~/.brauser/sessions")
+	flags.StringVar(&opts.cookieFile, "cookies", "", "Load and save cookies in this file (.txt for Netscape format, JSON otherwise)")
+	useCache := flags.Bool("cache", false, "Cache responses in the default cache directory")
+	flags.StringVar(&opts.cacheDir, "cache-dir", "", "Cache responses in this directory")
+	flags.BoolVar(&opts.offline, "offline", false, "Serve pages only from the cache")
+	flags.StringVar(&opts.harRecord, "har-record", "", "Record all requests and responses into this HAR file")
+	flags.StringVar(&opts.harReplay, "har-replay", "", "Serve all requests from this HAR file instead of the network")
+	polite := flags.Bool("polite", false, "Limit requests per host and honor robots.txt Crawl-delay and Retry-After")
+	rate := flags.Float64("rate", 0, "Maximum requests per second and host (implies --polite)")
+	maxPerHost := flags.Int("max-per-host", 0, "Maximum concurrent requests per host (implies --polite)")
+	robots := flags.String("robots", "warn", "What to do with pages disallowed by robots.txt: ignore, warn or enforce")
+	configPath := flags.String("config", "", "Read settings from this file instead of ~/.brauser/config.json")
+	proxy := flags.String("proxy", "", "Send requests through this http:// or socks5:// proxy, with optional user:password")
+	noProxy := flags.String("no-proxy", "", "Comma-separated hosts, domains and CIDR ranges that bypass the proxy")
+	caCerts := flags.String("ca-cert", "", "Comma-separated PEM files with additional trusted root certificates")
+	clientCert := flags.String("client-cert", "", "PEM client certificate for mutual TLS")
+	clientKey := flags.String("client-key", "", "PEM private key for the client certificate")
+	tlsMin := flags.String("tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	insecureHosts := flags.String("insecure-host", "", "Comma-separated hosts whose certificates are not verified")
+	maxPageSize := flags.String("max-page-size", "10MB", "Largest page loaded after decompression, 0 for no limit")
+	maxImageSize := flags.String("max-image-size", "5MB", "Largest image downloaded for rendering, 0 for no limit")
+	flags.StringVar(&opts.saveDir, "save-dir", "", "Save downloads, binary and oversized responses into this directory (default ~/Downloads)")
+	blockPrivate := flags.Bool("block-private", false, "Refuse to connect to loopback, private, link-local and cloud metadata addresses")
+	allowPrivate := flags.String("allow-private", "", "Comma-separated IPs, CIDR ranges and hosts exempt from --block-private")
+	flags.StringVar(&opts.baseURL, "base", "", "Resolve relative links of a page read from standard input against this URL")
+	flags.BoolVar(&opts.reader, "reader", false, "Show only the main content of articles, with byline, date and lead image")
+	profile := flags.String("profile", "", "Header profile for the session: desktop-chrome, mobile-safari, honest-bot or one from the config")
+	var extraHeaders []string
+	flags.Func("header", "Send this \"Name: value\" header with every request (repeatable)", func(value string) error {
+		if !strings.Contains(value, ":") {
+			return fmt.Errorf("header %q must look like \"Name: value\"", value)
+		}
+		extraHeaders = append(extraHeaders, value)
+		return nil
+	})
+
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != 1 {
+		return nil, fmt.Errorf("expected exactly one URL")
+	}
+	opts.enableRetry = !*noRetry
+	
+	// Local paths become file:// URLs and "-" reads the page from standard input
+	var err error
+	if opts.url, err = browser.NormalizeURL(positional[0]); err != nil {
+		return nil, err
+	}
+	if opts.url == "-" && opts.baseURL == "" {
+		if opts.baseURL, err = browser.FileURL("."); err != nil {
+			return nil, err
+		}
+	}
+	if opts.maxPageSize, err = browser.ParseSize(*maxPageSize); err != nil {
+		return nil, err
+	}
+	if opts.maxImageSize, err = browser.ParseSize(*maxImageSize); err != nil {
+		return nil, err
+	}
+	
+	robotsMode, err := browser.ParseRobotsMode(*robots)
+	if err != nil {
+		return nil, err
+	}
+	opts.robotsMode = robotsMode
+
+	if opts.cookieFile == "" && opts.session != "" {
+		path, err := browser.SessionCookiePath(opts.session)
+		if err != nil {
+			return nil, err
+		}
+		opts.cookieFile = path
+	}
+	
+	// Settings from the config file, overridden by the command line
+	cfg, err := loadBrowserConfig(*configPath)
+	if err != nil {
+		return nil, err
+	}
+	opts.config = cfg
+	
+	network := cfg.Network
+	if *proxy != "" {
+		network.Proxy = *proxy
+	}
+	if *noProxy != "" {
+		network.NoProxy = *noProxy
+	}
+	if *caCerts != "" {
+		network.CACerts = splitList(*caCerts)
+	}
+	if *clientCert != "" {
+		network.ClientCert = *clientCert
+	}
+	if *clientKey != "" {
+		network.ClientKey = *clientKey
+	}
+	if *tlsMin != "" {
+		network.MinTLSVersion = *tlsMin
+	}
+	if *insecureHosts != "" {
+		network.InsecureHosts = splitList(*insecureHosts)
+	}
+	if *allowPrivate != "" {
+		network.AllowPrivate = splitList(*allowPrivate)
+	}
+	// Downloads go to --save-dir, the configured directory or ~/Downloads
+	if opts.saveDir == "" {
+		opts.saveDir = expandHome(cfg.Downloads.Dir)
+	}
+	if opts.saveDir == "" {
+		if opts.saveDir, err = browser.DefaultDownloadDir(); err != nil {
+			return nil, err
+		}
+	}
+	if path, err := browser.DefaultDownloadsListPath(); err == nil {
+		opts.downloadList = path
+	}
+	
+	opts.blockPrivate = *blockPrivate || network.BlockPrivate
+	opts.allowPrivate = network.AllowPrivate
+	
+	if len(cfg.Policy.Allow) > 0 || len(cfg.Policy.Deny) > 0 {
+		policy, err := browser.NewURLPolicy(urlRules(cfg.Policy.Allow), urlRules(cfg.Policy.Deny))
+		if err != nil {
+			return nil, err
+		}
+		opts.urlPolicy = policy
+	}
+	
+	// Header profiles from the config file; --profile and --header take precedence
+	opts.headers = cfg.Headers
+	if *profile != "" {
+		opts.headers.Profile = *profile
+	}
+	if len(extraHeaders) > 0 {
+		extra := make(map[string]string, len(cfg.Headers.Extra)+len(extraHeaders))
+		for name, value := range cfg.Headers.Extra {
+			extra[name] = value
+		}
+		for _, header := range extraHeaders {
+			name, value, _ := strings.Cut(header, ":")
+			extra[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		opts.headers.Extra = extra
+	}
+	
+	// Credentials from the config file win over BRAUSER_AUTH, which wins over ~/.netrc
+	if opts.credentials, err = loadCredentials(cfg.Auth); err != nil {
+		return nil, err
+	}
+	
+	minTLSVersion, err := browser.ParseTLSVersion(network.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+	opts.network = browser.NetworkOptions{
+		ProxyURL:       network.Proxy,
+		NoProxy:        network.NoProxy,
+		CAFiles:        network.CACerts,
+		ClientCertFile: network.ClientCert,
+		ClientKeyFile:  network.ClientKey,
+		MinTLSVersion:  minTLSVersion,
+		InsecureHosts:  network.InsecureHosts,
+	}
+	
+	if *polite || *rate > 0 || *maxPerHost > 0 {
+		policy := browser.DefaultPolitenessPolicy()
+		if *rate > 0 {
+			policy.RequestsPerSecond = *rate
+		}
+		if *maxPerHost > 0 {
+			policy.MaxConcurrentPerHost = *maxPerHost
+		}
+		opts.politeness = &policy
+	}
+	
+	if opts.cacheDir == "" && (*useCache || opts.offline) {
+		dir, err := browser.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		opts.cacheDir = dir
+	}
+
+	return opts, nil
+}
+
+// loadBrowserConfig reads the config file. Without an explicit path a missing
+// ~/.brauser/config.json is not an error.
+func loadBrowserConfig(path string) (*config.BrowserConfig, error) {
+	explicit := path != ""
+	if !explicit {
+		defaultPath, err := config.DefaultBrowserConfigPath()
+		if err != nil {
+			return &config.BrowserConfig{}, nil
+		}
+		path = defaultPath
+	}
+	
+	cfg, err := config.LoadBrowserConfig(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &config.BrowserConfig{}, nil
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadCredentials collects per-host credentials from the config file, the
+// BRAUSER_AUTH environment variable and the netrc file
+func loadCredentials(entries []config.AuthConfig) (*browser.CredentialStore, error) {
+	store := browser.NewCredentialStore()
+	for _, entry := range entries {
+		credentials := browser.Credentials{Username: entry.Username, Password: entry.Password, Token: entry.Token, Insecure: entry.Insecure}
+		if entry.PasswordEnv != "" {
+			credentials.Password = os.Getenv(entry.PasswordEnv)
+		}
+		if entry.TokenEnv != "" {
+			credentials.Token = os.Getenv(entry.TokenEnv)
+		}
+		if entry.Host == "" || credentials.Token == "" && credentials.Username == "" {
+			return nil, fmt.Errorf("auth entry for %q needs a host and a username or token", entry.Host)
+		}
+		store.Set(entry.Host, credentials)
+	}
+	
+	env := browser.NewCredentialStore()
+	if err := env.LoadEnv(os.Getenv("BRAUSER_AUTH")); err != nil {
+		return nil, fmt.Errorf("BRAUSER_AUTH: %v", err)
+	}
+	env.Each(func(host string, credentials browser.Credentials) {
+		if _, exists := store.Lookup(host); !exists {
+			store.Set(host, credentials)
+		}
+	})
+	
+	if path, err := browser.DefaultNetrcPath(); err == nil {
+		if err := store.LoadNetrc(path); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// applyHeaderProfiles registers the custom profiles from the config file and
+// selects the profiles for the session and for individual hosts
+func applyHeaderProfiles(client *browser.Client, headers config.HeadersConfig) error {
+	names := make([]string, 0, len(headers.Profiles))
+	for name := range headers.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		custom := headers.Profiles[name]
+		extends := custom.Extends
+		if extends == "" {
+			extends = browser.DefaultProfile
+		}
+		base, ok := client.LookupProfile(extends)
+		if !ok {
+			return fmt.Errorf("profile %q extends unknown profile %q", name, extends)
+		}
+		profile := base.Clone(strings.ToLower(name))
+		if custom.UserAgent != "" {
+			profile.UserAgent = custom.UserAgent
+		}
+		for header, value := range custom.Headers {
+			profile.Headers.Set(header, value)
+		}
+		if custom.Platform != "" {
+			profile.Platform = custom.Platform
+		}
+		if custom.WindowWidth > 0 && custom.WindowHeight > 0 {
+			profile.WindowWidth, profile.WindowHeight = custom.WindowWidth, custom.WindowHeight
+		}
+		client.AddProfile(profile)
+	}
+	
+	for host, name := range headers.Hosts {
+		if err := client.SetHostProfile(host, name); err != nil {
+			return err
+		}
+	}
+	for name, value := range headers.Extra {
+		client.SetHeader(name, value)
+	}
+	if headers.Profile != "" {
+		return client.SetProfile(headers.Profile)
+	}
+	return nil
+}
+
+// fingerprintFor describes the profile used for a page to the JavaScript
+// stubs, so scripts see the same browser as the server did
+func fingerprintFor(client *browser.Client, pageURL string) js.Fingerprint {
+	host := ""
+	if parsed, err := url.Parse(pageURL); err == nil {
+		host = parsed.Hostname()
+	}
+	profile := client.Profile(host)
+	return js.Fingerprint{
+		UserAgent:      profile.UserAgent,
+		Platform:       profile.Platform,
+		Vendor:         profile.Vendor,
+		Languages:      profile.Languages,
+		Brands:         profile.Brands,
+		Mobile:         profile.Mobile,
+		MaxTouchPoints: profile.MaxTouchPoints,
+		WindowWidth:    profile.WindowWidth,
+		WindowHeight:   profile.WindowHeight,
+		ScreenWidth:    profile.ScreenWidth,
+		ScreenHeight:   profile.ScreenHeight,
+	}
+}
+
+// urlRules converts URL policy rules from the config file
+func urlRules(rules []config.URLRuleConfig) []browser.URLRule {
+	converted := make([]browser.URLRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, browser.URLRule{
+			Scheme:     rule.Scheme,
+			Host:       rule.Host,
+			PathPrefix: rule.PathPrefix,
+			Regex:      rule.Regex,
+		})
+	}
+	return converted
+}
+
+// splitList splits a comma-separated command line value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // main is the entry point of the Brauser application with interactive navigation.
 func main() {
 	fmt.Println("Brauser: Minimalistic Terminal Web Browser with Interactive Navigation")
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: brauser <url> [--no-retry]")
-		fmt.Println("  --no-retry: Disable content detection and retry logic")
-		fmt.Println("  Interactive features: numbered links, back/forward, URL bar")
+		printUsage()
 		return
 	}
 	
-	initialURL := os.Args[1]
-	enableRetry := true
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		printUsage()
+		os.Exit(2)
+	}
 	
-	// Check for --no-retry flag
-	if len(os.Args) > 2 && os.Args[2] == "--no-retry" {
-		enableRetry = false
+	if !opts.enableRetry {
 		fmt.Println("Content detection and retry logic disabled")
 	}
 	
 	// Create components
 	client := browser.NewClient()
 	htmlRenderer := renderer.NewHTMLRenderer()
+	htmlRenderer.SetHTTPClient(client.HTTPClient())
 	navigator := navigation.NewNavigator()
 	
+	// Proxy and TLS settings
+	if err := client.ConfigureNetwork(opts.network); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if opts.network.ProxyURL != "" {
+		fmt.Printf("🔀 Using proxy %s\n", redactProxyURL(opts.network.ProxyURL))
+	}
+	
+	// Restrict browsing to the URLs permitted by the config file
+	if policy := opts.urlPolicy; policy != nil {
+		client.SetURLPolicy(policy)
+		navigator.SetURLPolicy(policy)
+		if err := policy.Check(opts.url); err != nil && opts.url != "-" {
+			displayLoadError(err)
+			os.Exit(1)
+		}
+	}
+	
+	// Keep requests away from internal addresses
+	if opts.blockPrivate {
+		if err := client.EnableSSRFGuard(opts.allowPrivate); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("🛡️  Private, loopback and metadata addresses are blocked")
+	}
+	
+	// Size limits and saving of downloads
+	client.SetMaxDocumentSize(opts.maxPageSize)
+	htmlRenderer.SetMaxImageSize(opts.maxImageSize)
+	htmlRenderer.SetReaderMode(opts.reader)
+	client.SetStreamDir(opts.saveDir)
+	client.SetDownloadProgress(downloadProgress.report)
+	
+	// Credentials for hosts behind Basic, Digest or Bearer auth
+	client.SetCredentialStore(opts.credentials)
+	
+	// Browser identity: headers, navigator and window size per host or session
+	if err := applyHeaderProfiles(client, opts.headers); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if opts.headers.Profile != "" {
+		fmt.Printf("🎭 Header profile: %s\n", client.Profile("").Name)
+	}
+	
+	// Replay a recorded session or start recording one
+	if opts.harReplay != "" {
+		if err := client.ReplayHAR(opts.harReplay); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📼 Replaying recorded session from %s\n", opts.harReplay)
+	}
+	if opts.harRecord != "" {
+		client.RecordHAR()
+		fmt.Printf("⏺️  Recording session to %s\n", opts.harRecord)
+	}
+	
+	// Throttle requests per host
+	if opts.politeness != nil {
+		client.EnablePoliteness(*opts.politeness)
+		fmt.Printf("🐢 Polite mode: %.2g requests/s and %d concurrent requests per host\n", opts.politeness.RequestsPerSecond, opts.politeness.MaxConcurrentPerHost)
+	}
+	
+	// Check pages against robots.txt
+	client.SetRobotsMode(opts.robotsMode)
+	if opts.robotsMode == browser.RobotsEnforce {
+		fmt.Println("🤖 Compliance mode: pages disallowed by robots.txt are refused")
+	}
+	
+	// Enable the HTTP cache and offline mode
+	if opts.cacheDir != "" {
+		if err := client.EnableCache(opts.cacheDir); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if opts.offline {
+			client.SetOfflineMode(true)
+			fmt.Println("📴 Offline mode: serving pages from the cache only")
+		}
+	}
+	
+	// Restore cookies from a previous run
+	if opts.cookieFile != "" {
+		if err := client.LoadCookies(opts.cookieFile); err != nil {
+			fmt.Printf("⚠️  Could not load cookies: %v\n", err)
+		}
+	}
+	
+	// A page piped on standard input is read once; commands then come from the terminal
+	if opts.url == "-" {
+		data, err := browser.ReadLimited(os.Stdin, opts.maxPageSize, "-")
+		if err != nil {
+			fmt.Printf("❌ Could not read standard input: %v\n", err)
+			os.Exit(1)
+		}
+		opts.stdin = data
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			navigator.SetInput(tty)
+		}
+	}
+	
 	// Start interactive browsing session
-	startInteractiveBrowsing(client, htmlRenderer, navigator, initialURL, enableRetry)
+	startInteractiveBrowsing(client, htmlRenderer, navigator, opts)
+}
+
+// printUsage prints the command line help
+func printUsage() {
+	fmt.Println("Usage: brauser <url|file|-> [--no-retry] [--session name] [--cookies file] [--cache] [--cache-dir dir] [--offline]")
+	fmt.Println("                     [--har-record file] [--har-replay file] [--polite] [--rate rps] [--max-per-host n]")
+	fmt.Println("                     [--robots ignore|warn|enforce] [--config file] [--proxy url] [--no-proxy hosts]")
+	fmt.Println("                     [--ca-cert files] [--client-cert file] [--client-key file] [--tls-min version]")
+	fmt.Println("                     [--insecure-host hosts] [--max-page-size size] [--max-image-size size] [--save-dir dir]")
+	fmt.Println("                     [--block-private] [--allow-private list] [--base url] [--profile name] [--header h]")
+	fmt.Println("                     [--reader]")
+	fmt.Println("  --no-retry:  Disable content detection and retry logic")
+	fmt.Println("  --session:   Keep cookies in a named session under ~/.brauser/sessions")
+	fmt.Println("  --cookies:   Load and save cookies in this file (.txt for Netscape format)")
+	fmt.Println("  --cache:     Cache responses in the default cache directory")
+	fmt.Println("  --cache-dir: Cache responses in this directory")
+	fmt.Println("  --offline:   Serve pages only from the cache")
+	fmt.Println("  --har-record: Record all requests and responses into a HAR file")
+	fmt.Println("  --har-replay: Serve all requests from a HAR file instead of the network")
+	fmt.Println("  --polite:    Limit requests per host and honor robots.txt Crawl-delay and Retry-After")
+	fmt.Println("  --rate:      Maximum requests per second and host (implies --polite)")
+	fmt.Println("  --max-per-host: Maximum concurrent requests per host (implies --polite)")
+	fmt.Println("  --robots:    Ignore robots.txt, warn about disallowed pages (default) or refuse them")
+	fmt.Println("  --config:    Read settings from this file instead of ~/.brauser/config.json")
+	fmt.Println("  --proxy:     Use an http://, https:// or socks5:// proxy (user:password@ for auth)")
+	fmt.Println("  --no-proxy:  Hosts, domains and CIDR ranges that bypass the proxy")
+	fmt.Println("  --ca-cert:   Trust the root certificates in these PEM files")
+	fmt.Println("  --client-cert, --client-key: Present a client certificate for mutual TLS")
+	fmt.Println("  --tls-min:   Minimum TLS version (1.0 to 1.3)")
+	fmt.Println("  --insecure-host: Skip certificate verification for these hosts only")
+	fmt.Println("  --max-page-size, --max-image-size: Size limits after decompression (e.g. 10MB, 0 for none)")
+	fmt.Println("  --save-dir:  Save downloads and oversized pages here (default ~/Downloads)")
+	fmt.Println("  --block-private: Refuse loopback, private, link-local and cloud metadata addresses")
+	fmt.Println("  --allow-private: IPs, CIDR ranges and hosts exempt from --block-private")
+	fmt.Println("  --base:      Base URL for relative links of a page read from standard input (brauser -)")
+	fmt.Println("  --reader:    Show only the main content of articles (toggle with 'reader')")
+	fmt.Println("  --profile:   Header profile: desktop-chrome (default), mobile-safari, honest-bot or a custom one")
+	fmt.Println("  --header:    Send \"Name: value\" with every request, overriding the profile (repeatable)")
+	fmt.Println("  Local files, file:// and data: URLs are rendered like web pages")
+	fmt.Println("  Interactive features: numbered links, forms, back/forward, URL bar")
+}
+
+// switchProfile selects the header profile for the session, or lists the
+// profiles when no name is given
+func switchProfile(client *browser.Client, name string) {
+	if name == "" {
+		current := client.Profile("").Name
+		fmt.Println("\n🎭 Header profiles:")
+		for _, profile := range client.ProfileNames() {
+			marker := "  "
+			if profile == current {
+				marker = "* "
+			}
+			fmt.Printf("  %s%s\n", marker, profile)
+		}
+		return
+	}
+	if err := client.SetProfile(name); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	fmt.Printf("🎭 Header profile: %s (applies from the next page load)\n", client.Profile("").Name)
+}
+
+// redactProxyURL hides the password of a proxy URL for display
+func redactProxyURL(proxyURL string) string {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return proxyURL
+	}
+	return parsed.Redacted()
+}
+
+// saveSessionState persists the cookie jar and the HAR recording if they were configured
+func saveSessionState(client *browser.Client, opts *options) {
+	if opts.cookieFile != "" {
+		if err := client.SaveCookies(opts.cookieFile); err != nil {
+			fmt.Printf("⚠️  Could not save cookies: %v\n", err)
+		}
+	}
+	if opts.harRecord != "" {
+		if err := client.RecordHAR().Save(opts.harRecord); err != nil {
+			fmt.Printf("⚠️  Could not save HAR recording: %v\n", err)
+		}
+	}
 }
 
 // startInteractiveBrowsing handles the main interactive browsing loop
-func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator, initialURL string, enableRetry bool) {
-	currentURL := initialURL
+func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator, opts *options) {
+	currentURL := opts.url
+	revalidate := false
+	var submission *browser.FormSubmission
+	referer := ""
 	
 	for {
 		// Fetch and display page
-		if err := loadAndDisplayPage(client, htmlRenderer, navigator, currentURL, enableRetry); err != nil {
-			fmt.Printf("❌ Error loading page: %v\n", err)
+		// On failure fall through to the menu so the user can retry or go elsewhere
+		fetchOpts := browser.FetchOptions{EnableRetry: opts.enableRetry, Revalidate: revalidate}
+		if submission != nil {
+			fetchOpts = submission.FetchOptions(referer)
+			fetchOpts.EnableRetry = opts.enableRetry
+		}
+		revalidate = false
+		err := loadAndDisplayPage(client, htmlRenderer, navigator, currentURL, fetchOpts, opts)
+		// Ask for credentials when the server wants them and load the page again
+		if err != nil && promptForCredentials(client, navigator, err) {
 			continue
 		}
+		submission = nil
+		if err != nil {
+			displayLoadError(err)
+		} else {
+			saveSessionState(client, opts)
+		}
 		
 		// Show navigation menu and get user input
 		navigator.ShowNavigationMenu()
@@ -57,6 +663,11 @@ func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTM
 		
 		for {
 			input, err := navigator.GetUserInput()
+			if errors.Is(err, io.EOF) {
+				saveSessionState(client, opts)
+				fmt.Println("\n👋 Thanks for using Brauser!")
+				return
+			}
 			if err != nil {
 				fmt.Printf("❌ Error reading input: %v\n", err)
 				continue
@@ -98,7 +709,10 @@ func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTM
 				
 			case "url":
 				newURL, err := navigator.PromptForURL()
-				if err != nil {
+				var policyErr *browser.PolicyViolationError
+				if errors.As(err, &policyErr) {
+					displayLoadError(err)
+				} else if err != nil {
 					fmt.Printf("❌ Error: %v\n", err)
 				} else {
 					currentURL = newURL
@@ -108,12 +722,60 @@ func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTM
 				
 			case "refresh":
 				fmt.Printf("🔄 Refreshing: %s\n", currentURL)
+				revalidate = true
 				goto loadPage
 				
 			case "quit":
+				saveSessionState(client, opts)
 				fmt.Println("👋 Thanks for using Brauser!")
 				return
 				
+			case "download":
+				target := data.(string)
+				checksum := ""
+				if target == "" {
+					target = currentURL
+				} else if page := navigator.GetCurrentPage(); page != nil {
+					checksum = browser.FindChecksum(page.Content, downloadFileName(target))
+				}
+				downloadFile(client, target, checksum, opts)
+				
+			case "downloads":
+				showDownloads(opts)
+				
+			case "forms":
+				navigator.DisplayForms()
+				
+			case "reader":
+				htmlRenderer.SetReaderMode(!htmlRenderer.ReaderMode())
+				if htmlRenderer.ReaderMode() {
+					fmt.Println("📖 Reader mode on: only the main content of articles is shown")
+				} else {
+					fmt.Println("📄 Reader mode off: whole pages are shown")
+				}
+				if entry := navigator.GetCurrentPage(); entry != nil {
+					displayCachedPage(entry, htmlRenderer, navigator)
+				}
+				
+			case "submit":
+				submission = data.(*browser.FormSubmission)
+				referer = ""
+				if page := navigator.GetCurrentPage(); page != nil {
+					referer = page.URL
+				}
+				currentURL = submission.URL
+				fmt.Printf("📨 Submitting form: %s %s\n", submission.Method, currentURL)
+				goto loadPage
+				
+			case "info":
+				fmt.Printf("✅ %s\n", data.(string))
+				
+			case "profile":
+				switchProfile(client, data.(string))
+				
+			case "blocked":
+				displayLoadError(data.(error))
+				
 			case "error":
 				fmt.Printf("❌ %s\n", data.(string))
 			}
@@ -125,70 +787,364 @@ func startInteractiveBrowsing(client *browser.Client, htmlRenderer *renderer.HTM
 }
 
 // loadAndDisplayPage fetches, renders, and processes a web page
-func loadAndDisplayPage(client *browser.Client, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator, url string, enableRetry bool) error {
-	// Fetch page content
-	var content string
-	var analysis *browser.ContentAnalysis
-	var err error
+func loadAndDisplayPage(client *browser.Client, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator, url string, fetchOpts browser.FetchOptions, opts *options) error {
+	// Ctrl+C aborts the page load instead of exiting the browser
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	
-	if enableRetry {
-		content, analysis, err = client.FetchPageWithEnhancedDetection(url)
-		if err != nil {
-			return fmt.Errorf("failed to fetch page: %v", err)
-		}
-		
-		// Display content analysis results
-		displayContentAnalysis(analysis)
+	// Fetch page content, or take it from standard input
+	var result *browser.PageResult
+	var err error
+	if url == "-" {
+		result, err = client.LoadDocument(ctx, bytes.NewReader(opts.stdin), opts.baseURL, "")
 	} else {
-		content, err = client.FetchPageWithRetry(url, false)
-		if err != nil {
-			return fmt.Errorf("failed to fetch page: %v", err)
+		result, err = client.Fetch(ctx, url, fetchOpts)
+	}
+	if err != nil {
+		// Error pages are shown and kept in history, marked with their status
+		var statusErr *browser.HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.Page != nil && strings.TrimSpace(statusErr.Page.Content) != "" {
+			if renderErr := displayPage(ctx, client, htmlRenderer, navigator, statusErr.Page, opts); renderErr != nil {
+				return renderErr
+			}
 		}
+		return fmt.Errorf("failed to fetch page: %w", err)
 	}
 	
-	// Render HTML content
-	doc, err := htmlRenderer.RenderHTML(content, url)
+	// Downloads streamed to disk have no page to render; the page that linked
+	// to them may publish a checksum
+	if result.SavedPath != "" {
+		downloadProgress.end()
+		checksum := ""
+		if page := navigator.GetCurrentPage(); page != nil {
+			checksum = browser.FindChecksum(page.Content, downloadFileName(result.FinalURL))
+		}
+		completeDownload(&browser.Download{
+			URL:         result.FinalURL,
+			Path:        result.SavedPath,
+			Size:        result.SavedSize,
+			ContentType: result.ContentType,
+			Completed:   time.Now(),
+		}, checksum, opts)
+		return nil
+	}
+	return displayPage(ctx, client, htmlRenderer, navigator, result, opts)
+}
+
+// displayPage renders a loaded page and adds it to the history
+func displayPage(ctx context.Context, client *browser.Client, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator, result *browser.PageResult, opts *options) error {
+	// Display content analysis results and warnings
+	displayContentAnalysis(result)
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	
+	// Render the content by its type, relative to the final URL
+	doc, err := htmlRenderer.RenderPage(ctx, result)
 	if err != nil {
 		return fmt.Errorf("failed to render HTML: %v", err)
 	}
 	
+	// Files that cannot be shown are offered for download
+	if result.Kind() == browser.KindBinary {
+		offerDownload(navigator, result, opts)
+	}
+	
 	// Execute embedded JavaScript
 	title := doc.Find("title").Text()
-	js.ExecuteJS(doc, title)
+	js.ExecuteJS(doc, title, fingerprintFor(client, result.FinalURL))
 	
-	// Extract links for navigation
-	navigator.ExtractLinks(doc, url)
+	// Extract links and forms for navigation
+	navigator.ExtractLinks(doc, result.FinalURL)
+	navigator.ExtractForms(doc, result.FinalURL)
 	
 	// Add to history
-	navigator.AddToHistory(url, title, content)
+	navigator.AddPageToHistory(result, title)
 	
 	return nil
 }
 
-// displayCachedPage shows a cached page from history and re-extracts links
-func displayCachedPage(entry *navigation.HistoryEntry, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator) {
-	// Re-render the cached HTML content to display it properly
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(entry.Content))
+// displayLoadError explains why a page could not be loaded, based on the error type
+func displayLoadError(err error) {
+	var statusErr *browser.HTTPStatusError
+	var timeoutErr *browser.TimeoutError
+	var dnsErr *browser.DNSError
+	var tlsErr *browser.TLSError
+	var tooLargeErr *browser.TooLargeError
+	var notCachedErr *browser.NotCachedError
+	var harMissErr *browser.HARMissError
+	var robotsErr *browser.RobotsDisallowedError
+	var redirectErr *browser.RedirectError
+	var blockedErr *browser.BlockedAddressError
+	var policyErr *browser.PolicyViolationError
+	var checksumErr *browser.ChecksumMismatchError
+	
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Println("⏹️  Page load cancelled")
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == 404 || statusErr.StatusCode == 410:
+			fmt.Printf("🚫 Page not found: %s\n", statusErr.Status)
+		case statusErr.StatusCode == 401 || statusErr.StatusCode == 403:
+			fmt.Printf("🔒 Access denied: %s\n", statusErr.Status)
+		case statusErr.StatusCode == 429:
+			fmt.Printf("⏳ Rate limited: %s\n", statusErr.Status)
+		case statusErr.StatusCode >= 500:
+			fmt.Printf("💥 Server error: %s\n", statusErr.Status)
+		default:
+			fmt.Printf("❌ Request rejected: %s\n", statusErr.Status)
+		}
+		if statusErr.RetryAfter > 0 {
+			fmt.Printf("   Server asked to retry after %v\n", statusErr.RetryAfter)
+		}
+	case errors.As(err, &timeoutErr):
+		fmt.Printf("⌛ Timed out: %v\n", timeoutErr.Err)
+	case errors.As(err, &dnsErr):
+		fmt.Printf("🌐 Could not resolve host %s\n", dnsErr.Host)
+	case errors.As(err, &tlsErr):
+		fmt.Printf("🔐 Secure connection failed: %v\n", tlsErr.Err)
+	case errors.As(err, &notCachedErr):
+		fmt.Printf("📴 Offline and not cached: %s\n", notCachedErr.URL)
+	case errors.As(err, &redirectErr):
+		if redirectErr.Loop {
+			fmt.Printf("🔁 Redirect loop at %s\n", redirectErr.URL)
+		} else {
+			fmt.Printf("🔁 Too many redirects (%d), stopped at %s\n", len(redirectErr.Chain), redirectErr.URL)
+		}
+	case errors.As(err, &policyErr):
+		fmt.Printf("🚫 Not permitted by the URL policy: %s\n", policyErr.URL)
+		if policyErr.Rule != "" {
+			fmt.Printf("   Matched rule: %s\n", policyErr.Rule)
+		}
+	case errors.As(err, &blockedErr):
+		fmt.Printf("🛡️  Blocked: %s resolves to a %s address (%s)\n", blockedErr.Host, blockedErr.Reason, blockedErr.IP)
+	case errors.As(err, &robotsErr):
+		fmt.Printf("🤖 Refused by robots.txt: %s\n", robotsErr.URL)
+	case errors.As(err, &harMissErr):
+		fmt.Printf("📼 Not in the recorded session: %s %s\n", harMissErr.Method, harMissErr.URL)
+	case errors.As(err, &tooLargeErr):
+		fmt.Printf("📦 Page too large (limit %d bytes)\n", tooLargeErr.Limit)
+		fmt.Println("   Raise --max-page-size or type 'download' to save it to disk")
+	case errors.As(err, &checksumErr):
+		fmt.Printf("🚨 %s checksum mismatch, the download was deleted\n", checksumErr.Algorithm)
+		fmt.Printf("   Expected %s\n   Got      %s\n", checksumErr.Expected, checksumErr.Actual)
+	default:
+		fmt.Printf("❌ Error loading page: %v\n", err)
+	}
+	
+	if browser.IsRetryable(err) {
+		fmt.Println("   Type 'r' to try again")
+	}
+}
+
+// promptForCredentials asks for credentials after a 401 with a challenge brauser
+// can answer and adds them to the client; it reports whether the page should be loaded again
+func promptForCredentials(client *browser.Client, navigator *navigation.Navigator, err error) bool {
+	var statusErr *browser.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized || statusErr.Page == nil {
+		return false
+	}
+	challenge, ok := browser.PreferredChallenge(browser.ParseAuthChallenges(statusErr.Page.Header))
+	if !ok {
+		return false
+	}
+	u, parseErr := url.Parse(statusErr.URL)
+	if parseErr != nil {
+		return false
+	}
+	credentials, ok := navigator.PromptCredentials(u.Hostname(), challenge)
+	if !ok {
+		return false
+	}
+	// Typed in for this page, so they may go where it came from, even over http
+	credentials.Insecure = u.Scheme == "http"
+	client.SetCredentials(u.Hostname(), credentials)
+	return true
+}
+
+// offerDownload asks whether to save a file that cannot be displayed and saves it into the save directory
+func offerDownload(navigator *navigation.Navigator, result *browser.PageResult, opts *options) {
+	if !navigator.Confirm(fmt.Sprintf("💾 Save this file to %s?", opts.saveDir)) {
+		return
+	}
+	savedPath, err := browser.SavePage(opts.saveDir, result)
 	if err != nil {
-		fmt.Printf("❌ Error parsing cached content: %v\n", err)
+		fmt.Printf("❌ %v\n", err)
 		return
 	}
+	completeDownload(&browser.Download{
+		URL:         result.FinalURL,
+		Path:        savedPath,
+		Size:        int64(len(result.Content)),
+		ContentType: result.ContentType,
+		Completed:   time.Now(),
+	}, "", opts)
+}
+
+// downloadFile saves a URL with the download manager, resuming a partial download
+func downloadFile(client *browser.Client, target, checksum string, opts *options) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	
-	// Render the cached content
-	_, err = htmlRenderer.RenderHTML(entry.Content, entry.URL)
+	fmt.Printf("⬇️  Downloading %s\n", target)
+	d, err := client.Download(ctx, target, browser.DownloadOptions{Dir: opts.saveDir, Checksum: checksum})
+	downloadProgress.end()
+	if err != nil {
+		displayLoadError(err)
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("💡 Run the download again to resume it")
+		}
+		return
+	}
+	if d.Resumed {
+		fmt.Println("⏯️  Resumed a partial download")
+	}
+	printDownload(d)
+	recordDownload(d, opts)
+}
+
+// completeDownload verifies a file saved while browsing against the checksum
+// published by the linking page and records it in the downloads list
+func completeDownload(d *browser.Download, checksum string, opts *options) {
+	if err := browser.VerifyDownload(d, checksum); err != nil {
+		var mismatch *browser.ChecksumMismatchError
+		if errors.As(err, &mismatch) {
+			os.Remove(d.Path)
+		}
+		displayLoadError(err)
+		return
+	}
+	printDownload(d)
+	recordDownload(d, opts)
+}
+
+// printDownload reports where a download was saved and whether its checksum was verified
+func printDownload(d *browser.Download) {
+	fmt.Printf("💾 Saved %s (%s) to %s\n", d.ContentType, formatBytes(d.Size), d.Path)
+	if d.Verified {
+		fmt.Printf("✅ Checksum verified (%s)\n", d.Checksum)
+	} else {
+		fmt.Printf("🔑 sha256:%s (no checksum published to verify against)\n", d.SHA256)
+	}
+}
+
+// recordDownload adds a completed download to the downloads list
+func recordDownload(d *browser.Download, opts *options) {
+	if opts.downloadList == "" {
+		return
+	}
+	if err := browser.RecordDownload(opts.downloadList, d); err != nil {
+		fmt.Printf("⚠️  Could not update the downloads list: %v\n", err)
+	}
+}
+
+// showDownloads lists the completed downloads, most recent last
+func showDownloads(opts *options) {
+	downloads, err := browser.LoadDownloads(opts.downloadList)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if len(downloads) == 0 {
+		fmt.Println("\n📥 No downloads yet.")
+		return
+	}
+	fmt.Printf("\n📥 DOWNLOADS (%d):\n", len(downloads))
+	fmt.Println(strings.Repeat("-", 50))
+	for i, d := range downloads {
+		status := "unverified"
+		if d.Verified {
+			status = "✅ verified"
+		}
+		fmt.Printf("  %d. %s  %s  %s\n     %s\n", i+1, d.Completed.Format("2006-01-02 15:04"), formatBytes(d.Size), status, d.Path)
+	}
+}
+
+// downloadFileName returns the last path element of a URL, used to find its checksum on the linking page
+func downloadFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// progressLine prints download progress on a single terminal line
+type progressLine struct {
+	active bool
+}
+
+// downloadProgress shows the progress of all downloads of the session
+var downloadProgress = &progressLine{}
+
+// report redraws the progress line
+func (p *progressLine) report(name string, written, total int64) {
+	if total > 0 {
+		fmt.Printf("\r⬇️  %s  %3d%% (%s / %s)", name, written*100/total, formatBytes(written), formatBytes(total))
+	} else {
+		fmt.Printf("\r⬇️  %s  %s", name, formatBytes(written))
+	}
+	p.active = true
+}
+
+// end finishes the progress line so the next output starts on a new line
+func (p *progressLine) end() {
+	if p.active {
+		fmt.Println()
+		p.active = false
+	}
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(value string) string {
+	if !strings.HasPrefix(value, "~/") {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, value[2:])
+}
+
+// displayCachedPage shows a cached page from history and re-extracts links
+func displayCachedPage(entry *navigation.HistoryEntry, htmlRenderer *renderer.HTMLRenderer, navigator *navigation.Navigator) {
+	// Re-render the cached content by its type
+	doc, err := htmlRenderer.RenderContent(context.Background(), entry.Content, entry.ContentType, entry.URL)
 	if err != nil {
 		fmt.Printf("❌ Error rendering cached content: %v\n", err)
 		return
 	}
 	
-	// Re-extract links from the cached content
+	// Re-extract links and forms from the cached content
 	navigator.ExtractLinks(doc, entry.URL)
+	navigator.ExtractForms(doc, entry.URL)
 	
 	fmt.Println("\n💾 (Displaying cached content - use 'r' to refresh)")
 }
 
-// displayContentAnalysis shows the content analysis results to the user
-func displayContentAnalysis(analysis *browser.ContentAnalysis) {
+// displayContentAnalysis shows the content analysis results of a fetched page to the user
+func displayContentAnalysis(result *browser.PageResult) {
+	analysis := result.Analysis
 	if analysis == nil {
 		return
 	}
@@ -222,9 +1178,15 @@ func displayContentAnalysis(analysis *browser.ContentAnalysis) {
 	
 	fmt.Printf("📊 Content length: %d characters\n", analysis.ContentLength)
 	
+	if result.Charset != "" {
+		fmt.Printf("🔤 Encoding: %s\n", result.Charset)
+	}
+	
+	fmt.Printf("⏱️  Fetched in %v (%d attempt(s))\n", result.Duration.Round(time.Millisecond), len(result.Attempts))
+	
 	if analysis.RequiresRetry {
 		fmt.Printf("🔁 Retry recommended (wait time: %v)\n", analysis.SuggestedWaitTime)
 	}
 	
-	fmt.Println("========================\n")
+	fmt.Print("========================\n\n")
 }
\ No newline at end of file
@@ -1,23 +1,52 @@
 package main
+
 import (
-	"net/http"
-	"net/http/httptest"
+	"context"
 	"testing"
+
+	"brauser/browser"
+	"brauser/navigation"
+	"brauser/renderer"
 )
 
-// TestFetchPage tests the fetchPage function with a mock server.
+// TestFetchPage loads and renders a recorded page from testdata without network access
 func TestFetchPage(t *testing.T) {
-	// Create a mock server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Test content"))
-	}))
-	defer server.Close()
+	client := browser.NewClient()
+	if err := client.ReplayHAR("testdata/fixture.har"); err != nil {
+		t.Fatal(err)
+	}
 
-	content, err := fetchPage(server.URL)
+	result, err := client.Fetch(context.Background(), "http://brauser.test/", browser.FetchOptions{})
 	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.StatusCode != 200 || result.MediaType() != "text/html" {
+		t.Errorf("Expected a 200 HTML page, got %d %q", result.StatusCode, result.ContentType)
+	}
+
+	htmlRenderer := renderer.NewHTMLRenderer()
+	htmlRenderer.SetHTTPClient(client.HTTPClient())
+	doc, err := htmlRenderer.RenderPage(context.Background(), result)
+	if err != nil {
+		t.Fatalf("Expected no render error, got %v", err)
+	}
+	if title := doc.Find("title").Text(); title != "Brauser Fixture" {
+		t.Errorf("Expected title \"Brauser Fixture\", got %q", title)
+	}
+
+	navigator := navigation.NewNavigator()
+	navigator.ExtractLinks(doc, result.FinalURL)
+	links := navigator.GetLinks()
+	if len(links) == 0 {
+		t.Fatal("Expected links to be extracted")
+	}
+	found := false
+	for _, link := range links {
+		if link.URL == "http://brauser.test/docs/guide" {
+			found = true
+		}
 	}
-	if content != "Test content" {
-		t.Errorf("Expected \"Test content\", got %q", content)
+	if !found {
+		t.Errorf("Expected the guide link to resolve against the page URL, got %+v", links)
 	}
-}
\ No newline at end of file
+}
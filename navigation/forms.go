@@ -0,0 +1,230 @@
+package navigation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"brauser/browser"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fillCommand matches "fill <form> <field> <value>"; the value keeps its case and spacing
+var fillCommand = regexp.MustCompile(`(?i)^\s*fill\s+(\d+)\s+(\S+)\s?(.*)$`)
+
+// ExtractForms collects the forms of the HTML document with numbered fields
+func (n *Navigator) ExtractForms(doc *goquery.Document, baseURL string) {
+	n.forms = browser.ParseForms(doc, baseURL)
+}
+
+// GetForms returns the forms of the current page
+func (n *Navigator) GetForms() []*browser.Form {
+	return n.forms
+}
+
+// form returns the form with the given number as typed by the user
+func (n *Navigator) form(number string) (*browser.Form, error) {
+	i, err := strconv.Atoi(number)
+	if err != nil || i < 1 || i > len(n.forms) {
+		if len(n.forms) == 0 {
+			return nil, fmt.Errorf("This page has no forms.")
+		}
+		return nil, fmt.Errorf("Form %s not found. Please choose a number between 1 and %d.", number, len(n.forms))
+	}
+	return n.forms[i-1], nil
+}
+
+// field returns a field of the form by number or name
+func (n *Navigator) field(form *browser.Form, ref string) (*browser.FormField, error) {
+	if i, err := strconv.Atoi(ref); err == nil {
+		if field := form.Field(i); field != nil {
+			return field, nil
+		}
+		return nil, fmt.Errorf("Field %d not found. Form %d has %d fields.", i, form.Number, len(form.Fields))
+	}
+	if field := form.FieldByName(ref); field != nil {
+		return field, nil
+	}
+	return nil, fmt.Errorf("Form %d has no field named %q.", form.Number, ref)
+}
+
+// DisplayForms shows the forms of the current page with their numbered fields
+func (n *Navigator) DisplayForms() {
+	if len(n.forms) == 0 {
+		fmt.Println("\n❌ No forms found on this page.")
+		return
+	}
+
+	fmt.Printf("\n📝 FORMS (%d total):\n", len(n.forms))
+	fmt.Println(strings.Repeat("-", 50))
+	for _, form := range n.forms {
+		fmt.Printf("\n[%d] %s %s", form.Number, form.Method, form.Action)
+		if form.Method == "POST" && form.Enctype != browser.FormURLEncoded {
+			fmt.Printf(" (%s)", form.Enctype)
+		}
+		if form.Name != "" {
+			fmt.Printf(" #%s", form.Name)
+		}
+		fmt.Println()
+		for _, field := range form.Fields {
+			fmt.Printf("  %s\n", describeField(field))
+		}
+	}
+	fmt.Println("\n  Type 'fill <form> <field> <value>', then 'submit <form> [button]' — or 'form <n>' to be asked for each field")
+}
+
+// describeField formats one numbered form field for display
+func describeField(field *browser.FormField) string {
+	label := field.Label
+	if label == "" {
+		label = field.Name
+	}
+	var line string
+	switch field.Type {
+	case "submit", "image":
+		line = fmt.Sprintf("%d. [ %s ] (button)", field.Number, label)
+	case "checkbox":
+		line = fmt.Sprintf("%d. %s %s", field.Number, field.Display(), label)
+	case "select", "radio":
+		options := make([]string, 0, len(field.Options))
+		for i, option := range field.Options {
+			marker := ""
+			if option.Selected {
+				marker = "*"
+			}
+			options = append(options, fmt.Sprintf("%d=%s%s", i+1, option.Label, marker))
+		}
+		line = fmt.Sprintf("%d. %s (%s): %s", field.Number, label, field.Type, strings.Join(options, ", "))
+	default:
+		line = fmt.Sprintf("%d. %s (%s) = %q", field.Number, label, field.Type, field.Display())
+		if field.Value == "" && field.Placeholder != "" && field.Placeholder != label {
+			line += fmt.Sprintf(" e.g. %q", field.Placeholder)
+		}
+	}
+	if field.Name != "" && field.Name != label {
+		line += " name=" + field.Name
+	}
+	if field.Required {
+		line += " *required"
+	}
+	if field.Disabled {
+		line += " (disabled)"
+	}
+	return line
+}
+
+// fillField handles "fill <form> <field> <value>"
+func (n *Navigator) fillField(input string) (action string, data interface{}) {
+	match := fillCommand.FindStringSubmatch(input)
+	if match == nil {
+		return "error", "Usage: fill <form> <field> <value>"
+	}
+	form, err := n.form(match[1])
+	if err != nil {
+		return "error", err.Error()
+	}
+	field, err := n.field(form, match[2])
+	if err != nil {
+		return "error", err.Error()
+	}
+	if err := field.Set(match[3]); err != nil {
+		return "error", err.Error()
+	}
+	return "info", fmt.Sprintf("Form %d: %s", form.Number, describeField(field))
+}
+
+// submitForm handles "submit [form] [button]"; the only form of a page needs no number
+func (n *Navigator) submitForm(args []string) (action string, data interface{}) {
+	number := "1"
+	if len(args) > 0 {
+		number = args[0]
+	} else if len(n.forms) > 1 {
+		return "error", fmt.Sprintf("This page has %d forms. Type 'submit <form> [button]'.", len(n.forms))
+	}
+	form, err := n.form(number)
+	if err != nil {
+		return "error", err.Error()
+	}
+	button := 0
+	if len(args) > 1 {
+		field, err := n.field(form, args[1])
+		if err != nil {
+			return "error", err.Error()
+		}
+		button = field.Number
+	}
+	return n.submission(form, button)
+}
+
+// submission encodes the form and checks the target against the URL policy
+func (n *Navigator) submission(form *browser.Form, button int) (action string, data interface{}) {
+	submission, err := form.Submit(button)
+	var violation *browser.PolicyViolationError
+	if errors.As(err, &violation) {
+		return "blocked", err
+	}
+	if err != nil {
+		return "error", err.Error()
+	}
+	if err := n.policy.Check(submission.URL); err != nil {
+		return "blocked", err
+	}
+	return "submit", submission
+}
+
+// fillFormInteractively asks for the value of each visible field of the form,
+// keeping the current value on an empty answer, and submits it
+func (n *Navigator) fillFormInteractively(number string) (action string, data interface{}) {
+	form, err := n.form(number)
+	if err != nil {
+		return "error", err.Error()
+	}
+	fmt.Printf("\n📝 Form %d: %s %s (press Enter to keep a value)\n", form.Number, form.Method, form.Action)
+
+	for _, field := range form.Fields {
+		if field.Disabled || field.IsButton() || field.Type == "hidden" {
+			continue
+		}
+		for {
+			fmt.Printf("  %s\n  > ", describeField(field))
+			var answer string
+			if field.Type == "password" {
+				answer = n.readSecret()
+			} else {
+				line, err := n.reader.ReadString('\n')
+				if err != nil && line == "" {
+					return "error", "Form filling cancelled."
+				}
+				answer = strings.TrimRight(line, "\r\n")
+			}
+			if answer == "" {
+				break
+			}
+			if err := field.Set(answer); err != nil {
+				fmt.Printf("  ❌ %v\n", err)
+				continue
+			}
+			break
+		}
+	}
+
+	button := 0
+	if buttons := form.Buttons(); len(buttons) > 1 {
+		fmt.Println("  Submit with:")
+		for _, b := range buttons {
+			fmt.Printf("    %d. %s\n", b.Number, b.Label)
+		}
+		fmt.Printf("  > ")
+		line, _ := n.reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			field, err := n.field(form, line)
+			if err != nil {
+				return "error", err.Error()
+			}
+			button = field.Number
+		}
+	}
+	return n.submission(form, button)
+}
@@ -3,13 +3,15 @@ package navigation
 import (
 	"bufio"
 	"fmt"
-	"net/url"
+	"io"
 	"os"
-	"regexp"
+	"os/exec"
 	"strconv"
 	"strings"
 
+	"brauser/browser"
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
 )
 
 // Link represents a clickable link with numbered selection
@@ -22,9 +24,12 @@ type Link struct {
 
 // HistoryEntry represents a page in the browser history
 type HistoryEntry struct {
-	URL     string
-	Title   string
-	Content string
+	URL         string
+	Title       string
+	Content     string
+	StatusCode  int    // 0 if the entry was not added from a fetch result
+	Failed      bool   // The fetch returned a status other than 2xx
+	ContentType string
 }
 
 // Navigator handles interactive navigation functionality
@@ -32,7 +37,11 @@ type Navigator struct {
 	history      []HistoryEntry
 	currentIndex int
 	links        []Link
+	forms        []*browser.Form
+	pageURL      string // URL of the page the links were extracted from
 	reader       *bufio.Reader
+	terminal     *os.File // Terminal behind reader, used to hide typed passwords
+	policy       *browser.URLPolicy
 }
 
 // NewNavigator creates a new navigation handler
@@ -42,9 +51,21 @@ func NewNavigator() *Navigator {
 		currentIndex: -1,
 		links:        make([]Link, 0),
 		reader:       bufio.NewReader(os.Stdin),
+		terminal:     os.Stdin,
 	}
 }
 
+// SetURLPolicy makes the navigator refuse links and URLs the policy does not permit
+func (n *Navigator) SetURLPolicy(policy *browser.URLPolicy) {
+	n.policy = policy
+}
+
+// SetInput makes the navigator read commands from r instead of standard input
+func (n *Navigator) SetInput(r io.Reader) {
+	n.reader = bufio.NewReader(r)
+	n.terminal, _ = r.(*os.File)
+}
+
 // AddToHistory adds a new page to the browser history
 func (n *Navigator) AddToHistory(url, title, content string) {
 	// Remove any forward history if we're not at the end
@@ -68,6 +89,16 @@ func (n *Navigator) AddToHistory(url, title, content string) {
 	}
 }
 
+// AddPageToHistory adds a fetched page to the browser history under its final URL
+func (n *Navigator) AddPageToHistory(page *browser.PageResult, title string) {
+	n.AddToHistory(page.FinalURL, title, page.Content)
+	
+	entry := &n.history[n.currentIndex]
+	entry.StatusCode = page.StatusCode
+	entry.ContentType = page.ContentType
+	entry.Failed = !page.IsSuccess()
+}
+
 // CanGoBack returns true if there's a previous page in history
 func (n *Navigator) CanGoBack() bool {
 	return n.currentIndex > 0
@@ -104,96 +135,47 @@ func (n *Navigator) GetCurrentPage() *HistoryEntry {
 	return nil
 }
 
-// cleanLinkText removes excessive whitespace and newlines from link text
-func (n *Navigator) cleanLinkText(text string) string {
-	// Remove all newlines and replace with single spaces
-	re := regexp.MustCompile(`\s+`)
-	cleaned := re.ReplaceAllString(text, " ")
-	return strings.TrimSpace(cleaned)
-}
-
-// ExtractLinks extracts all clickable links from the HTML document and assigns numbers
+// ExtractLinks numbers the links of the HTML document in document order, with
+// the same numbers the renderer shows after the link text
 func (n *Navigator) ExtractLinks(doc *goquery.Document, baseURL string) {
 	n.links = make([]Link, 0)
-	linkNumber := 1
-	
-	// Parse base URL for resolving relative links
-	base, err := url.Parse(baseURL)
-	if err != nil {
-		base = nil
+	n.pageURL = baseURL
+	for _, link := range browser.NumberLinks(doc, baseURL) {
+		n.links = append(n.links, Link{
+			Number: link.Number,
+			Text:   link.Text,
+			URL:    link.URL,
+			Type:   linkType(link.Node),
+		})
 	}
-	
-	// Extract navigation links
-	doc.Find("nav a, .nav a, .menu a").Each(func(i int, s *goquery.Selection) {
-		text := n.cleanLinkText(s.Text())
-		href, exists := s.Attr("href")
-		if exists && text != "" {
-			resolvedURL := n.resolveURL(href, base)
-			n.links = append(n.links, Link{
-				Number: linkNumber,
-				Text:   text,
-				URL:    resolvedURL,
-				Type:   "nav",
-			})
-			linkNumber++
+}
+
+// linkType groups a link as "nav" inside navigation and menus, "story" for
+// Hacker News stories and "content" otherwise
+func linkType(node *html.Node) string {
+	if parent := node.Parent; parent != nil && hasClass(parent, "titleline") {
+		return "story"
+	}
+	for ancestor := node.Parent; ancestor != nil; ancestor = ancestor.Parent {
+		if ancestor.Type == html.ElementNode && (ancestor.Data == "nav" || hasClass(ancestor, "nav") || hasClass(ancestor, "menu")) {
+			return "nav"
 		}
-	})
-	
-	// Extract content links
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		text := n.cleanLinkText(s.Text())
-		href, exists := s.Attr("href")
-		if exists && text != "" && len(text) > 3 && linkNumber <= 50 {
-			// Skip if already added as navigation
-			alreadyAdded := false
-			for _, link := range n.links {
-				if link.URL == href {
-					alreadyAdded = true
-					break
+	}
+	return "content"
+}
+
+// hasClass reports whether an element has the given class
+func hasClass(node *html.Node, class string) bool {
+	for _, attr := range node.Attr {
+		if attr.Key == "class" {
+			for _, name := range strings.Fields(attr.Val) {
+				if name == class {
+					return true
 				}
 			}
-			if !alreadyAdded {
-				resolvedURL := n.resolveURL(href, base)
-				n.links = append(n.links, Link{
-					Number: linkNumber,
-					Text:   text,
-					URL:    resolvedURL,
-					Type:   "content",
-				})
-				linkNumber++
-			}
 		}
-	})
-	
-	// Extract HackerNews stories
-	doc.Find(".athing").Each(func(i int, s *goquery.Selection) {
-		title := n.cleanLinkText(s.Find(".titleline > a").Text())
-		link := s.Find(".titleline > a").AttrOr("href", "")
-		if title != "" && link != "" && linkNumber <= 50 {
-			resolvedURL := n.resolveURL(link, base)
-			n.links = append(n.links, Link{
-				Number: linkNumber,
-				Text:   title,
-				URL:    resolvedURL,
-				Type:   "story",
-			})
-			linkNumber++
-		}
-	})
-}
-
-// resolveURL resolves relative URLs against the base URL
-func (n *Navigator) resolveURL(href string, base *url.URL) string {
-	if base == nil {
-		return href
-	}
-	
-	parsed, err := url.Parse(href)
-	if err != nil {
-		return href
 	}
-	
-	return base.ResolveReference(parsed).String()
+	return false
 }
 
 // DisplayLinks shows all numbered links to the user
@@ -226,7 +208,7 @@ func (n *Navigator) DisplayLinks() {
 	if len(navLinks) > 0 {
 		fmt.Println("\n🧭 Navigation:")
 		for _, link := range navLinks {
-			fmt.Printf("  [%d] %s\n", link.Number, link.Text)
+			fmt.Printf("  [%d] %s\n", link.Number, n.linkText(link))
 		}
 	}
 	
@@ -234,7 +216,7 @@ func (n *Navigator) DisplayLinks() {
 	if len(storyLinks) > 0 {
 		fmt.Println("\n📰 Stories:")
 		for _, link := range storyLinks {
-			fmt.Printf("  [%d] %s\n", link.Number, link.Text)
+			fmt.Printf("  [%d] %s\n", link.Number, n.linkText(link))
 		}
 	}
 	
@@ -242,11 +224,28 @@ func (n *Navigator) DisplayLinks() {
 	if len(contentLinks) > 0 {
 		fmt.Println("\n📄 Content Links:")
 		for _, link := range contentLinks {
-			fmt.Printf("  [%d] %s\n", link.Number, link.Text)
+			fmt.Printf("  [%d] %s\n", link.Number, n.linkText(link))
 		}
 	}
 }
 
+// linkText returns the display text of a link, marking links the URL policy refuses
+func (n *Navigator) linkText(link Link) string {
+	if n.policy.Check(link.URL) != nil {
+		return link.Text + " 🚫"
+	}
+	return link.Text
+}
+
+// maxLinkNumber returns the highest link number of the page
+func (n *Navigator) maxLinkNumber() int {
+	highest := 0
+	for _, link := range n.links {
+		highest = max(highest, link.Number)
+	}
+	return highest
+}
+
 // GetLinkByNumber returns the link with the specified number
 func (n *Navigator) GetLinkByNumber(number int) *Link {
 	for _, link := range n.links {
@@ -272,6 +271,9 @@ func (n *Navigator) ShowNavigationMenu() {
 	current := n.GetCurrentPage()
 	if current != nil {
 		fmt.Printf("📍 Current: %s\n", current.URL)
+		if current.Failed {
+			fmt.Printf("⚠️  Status: %d\n", current.StatusCode)
+		}
 		if current.Title != "" {
 			fmt.Printf("📄 Title: %s\n", current.Title)
 		}
@@ -279,13 +281,22 @@ func (n *Navigator) ShowNavigationMenu() {
 	
 	// Show navigation options
 	fmt.Println("\n🎯 Navigation Options:")
-	fmt.Println("  • Type a number [1-50] to follow a link")
+	if len(n.links) > 0 {
+		fmt.Printf("  • Type a number [1-%d] to follow a link, shown as text[N] in the page\n", n.maxLinkNumber())
+	}
 	fmt.Println("  • Type 'b' or 'back' to go back")
 	fmt.Println("  • Type 'f' or 'forward' to go forward")
 	fmt.Println("  • Type 'h' or 'history' to view history")
 	fmt.Println("  • Type 'l' or 'links' to show links again")
 	fmt.Println("  • Type 'u' or 'url' to enter a new URL")
 	fmt.Println("  • Type 'r' or 'refresh' to reload current page")
+	fmt.Println("  • Type 'd [number|url]' or 'download' to save a link or the current page")
+	fmt.Println("  • Type 'downloads' to list completed downloads")
+	fmt.Println("  • Type 'profile [name]' to list or switch header profiles")
+	fmt.Println("  • Type 'reader' to show only the article or the whole page")
+	if len(n.forms) > 0 {
+		fmt.Printf("  • Type 'forms' to fill and submit the %d form(s) on this page\n", len(n.forms))
+	}
 	fmt.Println("  • Type 'q' or 'quit' to exit")
 	
 	// Show back/forward status
@@ -311,14 +322,37 @@ func (n *Navigator) GetUserInput() (string, error) {
 
 // ProcessUserInput processes user input and returns the action to take
 func (n *Navigator) ProcessUserInput(input string) (action string, data interface{}) {
+	// URLs and form values keep their case, so these arguments are taken before lowercasing
+	if fields := strings.Fields(input); len(fields) > 0 {
+		switch strings.ToLower(fields[0]) {
+		case "download", "d":
+			return n.downloadTarget(fields[1:])
+		case "fill":
+			return n.fillField(input)
+		case "submit":
+			return n.submitForm(fields[1:])
+		}
+	}
 	input = strings.ToLower(strings.TrimSpace(input))
+	if fields := strings.Fields(input); len(fields) > 0 && fields[0] == "profile" {
+		return "profile", strings.Join(fields[1:], " ")
+	}
+	if fields := strings.Fields(input); len(fields) == 2 && fields[0] == "form" {
+		return n.fillFormInteractively(fields[1])
+	}
 	
 	// Handle numeric input (link selection)
 	if num, err := strconv.Atoi(input); err == nil {
 		if link := n.GetLinkByNumber(num); link != nil {
+			if err := browser.CheckNavigation(n.pageURL, link.URL); err != nil {
+				return "blocked", err
+			}
+			if err := n.policy.Check(link.URL); err != nil {
+				return "blocked", err
+			}
 			return "navigate", link.URL
 		} else {
-			return "error", fmt.Sprintf("Link number %d not found. Please choose a number between 1 and %d.", num, len(n.links))
+			return "error", fmt.Sprintf("Link number %d not found. Please choose a number between 1 and %d.", num, n.maxLinkNumber())
 		}
 	}
 	
@@ -344,6 +378,12 @@ func (n *Navigator) ProcessUserInput(input string) (action string, data interfac
 		return "url", nil
 	case "r", "refresh":
 		return "refresh", nil
+	case "downloads":
+		return "downloads", nil
+	case "forms", "form":
+		return "forms", nil
+	case "reader":
+		return "reader", nil
 	case "q", "quit":
 		return "quit", nil
 	default:
@@ -351,6 +391,31 @@ func (n *Navigator) ProcessUserInput(input string) (action string, data interfac
 	}
 }
 
+// downloadTarget resolves the argument of the download command: a link number,
+// a URL, or nothing for the current page
+func (n *Navigator) downloadTarget(args []string) (action string, data interface{}) {
+	if len(args) == 0 {
+		return "download", ""
+	}
+	target := args[0]
+	if num, err := strconv.Atoi(target); err == nil {
+		link := n.GetLinkByNumber(num)
+		if link == nil {
+			return "error", fmt.Sprintf("Link number %d not found. Please choose a number between 1 and %d.", num, n.maxLinkNumber())
+		}
+		if err := browser.CheckNavigation(n.pageURL, link.URL); err != nil {
+			return "blocked", err
+		}
+		target = link.URL
+	} else if normalized, err := browser.NormalizeURL(target); err == nil {
+		target = normalized
+	}
+	if err := n.policy.Check(target); err != nil {
+		return "blocked", err
+	}
+	return "download", target
+}
+
 // ShowHistory displays the browser history
 func (n *Navigator) ShowHistory() {
 	if len(n.history) == 0 {
@@ -373,8 +438,67 @@ func (n *Navigator) ShowHistory() {
 		}
 		
 		fmt.Printf("%s %d. %s\n", marker, i+1, title)
-		fmt.Printf("     %s\n", entry.URL)
+		if entry.Failed {
+			fmt.Printf("     %s (HTTP %d)\n", entry.URL, entry.StatusCode)
+		} else {
+			fmt.Printf("     %s\n", entry.URL)
+		}
+	}
+}
+
+// Confirm asks a yes/no question and reports whether the user answered yes
+func (n *Navigator) Confirm(question string) bool {
+	fmt.Printf("\n%s [y/N] ", question)
+	input, err := n.reader.ReadString('\n')
+	if err != nil && input == "" {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input))
+	return answer == "y" || answer == "yes"
+}
+
+// PromptCredentials asks for the credentials of a host that answered with a
+// 401. Bearer challenges ask for a token; an empty answer cancels.
+func (n *Navigator) PromptCredentials(host string, challenge browser.AuthChallenge) (browser.Credentials, bool) {
+	fmt.Printf("\n🔑 %s requires %s authentication", host, challenge.Scheme)
+	if realm := challenge.Realm(); realm != "" {
+		fmt.Printf(" (%s)", realm)
+	}
+	fmt.Println()
+	
+	if challenge.Scheme == "bearer" {
+		fmt.Print("   Token (empty to cancel): ")
+		token := n.readSecret()
+		return browser.Credentials{Token: token}, token != ""
+	}
+	
+	fmt.Print("   Username (empty to cancel): ")
+	username, _ := n.reader.ReadString('\n')
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return browser.Credentials{}, false
+	}
+	fmt.Print("   Password: ")
+	password := n.readSecret()
+	return browser.Credentials{Username: username, Password: password}, true
+}
+
+// readSecret reads a line without echoing it when the input is a terminal
+func (n *Navigator) readSecret() string {
+	if n.terminal != nil {
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = n.terminal
+		if stty.Run() == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = n.terminal
+				restore.Run()
+				fmt.Println()
+			}()
+		}
 	}
+	input, _ := n.reader.ReadString('\n')
+	return strings.TrimRight(input, "\r\n")
 }
 
 // PromptForURL prompts the user to enter a new URL
@@ -385,15 +509,14 @@ func (n *Navigator) PromptForURL() (string, error) {
 		return "", err
 	}
 	
-	url := strings.TrimSpace(input)
-	if url == "" {
-		return "", fmt.Errorf("empty URL")
+	// Local paths become file:// URLs, bare host names get https://
+	url, err := browser.NormalizeURL(input)
+	if err != nil {
+		return "", err
 	}
 	
-	// Add http:// if no protocol specified
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "https://" + url
+	if err := n.policy.Check(url); err != nil {
+		return "", err
 	}
-	
 	return url, nil
 }
\ No newline at end of file
@@ -0,0 +1,394 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"brauser/browser"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Default folding of pretty-printed JSON
+const (
+	DefaultJSONMaxDepth = 8
+	DefaultJSONMaxItems = 100
+)
+
+// RenderContent renders content of any type, choosing the renderer from
+// contentType and the content itself
+func (r *HTMLRenderer) RenderContent(ctx context.Context, content, contentType, baseURL string) (*goquery.Document, error) {
+	return r.renderContent(ctx, content, contentType, baseURL, nil)
+}
+
+// renderContent dispatches to the HTML renderer or the renderer for the content kind
+func (r *HTMLRenderer) renderContent(ctx context.Context, content, contentType, baseURL string, page *browser.PageResult) (*goquery.Document, error) {
+	kind := browser.DetectContentKind(contentType, []byte(content))
+	if kind == browser.KindHTML {
+		return r.render(ctx, content, baseURL, page)
+	}
+
+	r.println("\n" + strings.Repeat("=", 60))
+	r.println("           BRAUSER - TERMINAL WEB CONTENT")
+	r.println(strings.Repeat("=", 60))
+	if page != nil {
+		r.renderPageInfo(page)
+	}
+
+	// Documents without HTML still get a document so title and links can be extracted
+	docHTML := ""
+	summary := ""
+	switch kind {
+	case browser.KindJSON:
+		formatted, err := formatJSON([]byte(content), r.jsonMaxDepth, r.jsonMaxItems)
+		if err != nil {
+			r.printf("\n⚠️  Invalid JSON (%v), showing it as text\n", err)
+			formatted = content
+		}
+		r.printf("\n%s\n", formatted)
+		summary = fmt.Sprintf("JSON document, %d bytes", len(content))
+	case browser.KindXML:
+		tree, err := parseXMLTree(content)
+		if err != nil {
+			r.printf("\n⚠️  Invalid XML (%v), showing it as text\n", err)
+			r.printf("\n%s\n", content)
+		} else {
+			r.println("")
+			r.renderXMLNode(tree, "", "")
+			docHTML = feedDocument(tree)
+		}
+		summary = fmt.Sprintf("XML document, %d bytes", len(content))
+	case browser.KindImage:
+		asciiArt, err := r.imageRenderer.RenderImageData([]byte(content))
+		if err != nil {
+			r.printf("\n🖼️  [Image conversion failed: %v]\n", err)
+		} else {
+			r.println("\n🖼️  IMAGE:")
+			r.println(asciiArt)
+		}
+		summary = fmt.Sprintf("%s, %d bytes", mediaTypeName(contentType), len(content))
+	case browser.KindText:
+		text := strings.TrimRight(content, "\n")
+		r.printf("\n%s\n", text)
+		summary = fmt.Sprintf("text, %d lines", strings.Count(text, "\n")+1)
+	default:
+		r.printf("\n📦 This is a %s file (%d bytes) that cannot be shown in the terminal\n", mediaTypeName(contentType), len(content))
+		summary = fmt.Sprintf("%s, %d bytes", mediaTypeName(contentType), len(content))
+	}
+
+	r.printf("\n%s", strings.Repeat("=", 60))
+	r.printf("\n📊 CONTENT SUMMARY: %s\n", summary)
+	r.println(strings.Repeat("=", 60))
+	r.flushOutput()
+
+	return goquery.NewDocumentFromReader(strings.NewReader(docHTML))
+}
+
+// SetJSONFolding sets how deep JSON is shown before objects and arrays are
+// folded, and how many members are shown per object or array. 0 disables folding.
+func (r *HTMLRenderer) SetJSONFolding(maxDepth, maxItems int) {
+	r.jsonMaxDepth = maxDepth
+	r.jsonMaxItems = maxItems
+}
+
+// mediaTypeName returns the media type without parameters, or "binary" when it is unknown
+func mediaTypeName(contentType string) string {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if mediaType == "" {
+		return "binary"
+	}
+	return mediaType
+}
+
+// jsonFormatter pretty-prints JSON from a token stream, keeping the key order
+type jsonFormatter struct {
+	dec      *json.Decoder
+	out      *strings.Builder
+	maxDepth int
+	maxItems int
+}
+
+// formatJSON indents data and folds objects and arrays beyond maxDepth or maxItems
+func formatJSON(data []byte, maxDepth, maxItems int) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	f := &jsonFormatter{dec: dec, out: &strings.Builder{}, maxDepth: maxDepth, maxItems: maxItems}
+
+	// JSON Lines and concatenated values are shown one after another
+	for count := 0; dec.More(); count++ {
+		if count > 0 {
+			f.out.WriteString("\n")
+		}
+		if err := f.value(0); err != nil {
+			return "", err
+		}
+	}
+	return f.out.String(), nil
+}
+
+// value writes the next value at the given nesting depth
+func (f *jsonFormatter) value(depth int) error {
+	token, err := f.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		f.out.WriteString(jsonScalar(token))
+		return nil
+	}
+
+	isObject := delim == '{'
+	unit := "items"
+	if isObject {
+		unit = "keys"
+	}
+	if f.maxDepth > 0 && depth >= f.maxDepth {
+		count, err := f.skipMembers(isObject)
+		if err != nil {
+			return err
+		}
+		if isObject {
+			fmt.Fprintf(f.out, "{…%d %s}", count, unit)
+		} else {
+			fmt.Fprintf(f.out, "[…%d %s]", count, unit)
+		}
+		return nil
+	}
+
+	f.out.WriteString(string(delim))
+	indent := strings.Repeat("  ", depth+1)
+	count := 0
+	for f.dec.More() {
+		if f.maxItems > 0 && count >= f.maxItems {
+			rest, err := f.skipMembers(isObject)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(f.out, ",\n%s… %d more %s\n%s", indent, rest, unit, indent[2:])
+			if isObject {
+				f.out.WriteString("}")
+			} else {
+				f.out.WriteString("]")
+			}
+			return nil
+		}
+		if count > 0 {
+			f.out.WriteString(",")
+		}
+		f.out.WriteString("\n" + indent)
+		if isObject {
+			key, err := f.dec.Token()
+			if err != nil {
+				return err
+			}
+			f.out.WriteString(jsonScalar(key) + ": ")
+		}
+		if err := f.value(depth + 1); err != nil {
+			return err
+		}
+		count++
+	}
+
+	closing, err := f.dec.Token()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		f.out.WriteString("\n" + indent[2:])
+	}
+	f.out.WriteString(closing.(json.Delim).String())
+	return nil
+}
+
+// skipMembers consumes the remaining members of the current object or array,
+// including its closing delimiter, and returns how many there were
+func (f *jsonFormatter) skipMembers(isObject bool) (int, error) {
+	count := 0
+	for f.dec.More() {
+		if isObject {
+			if _, err := f.dec.Token(); err != nil {
+				return count, err
+			}
+		}
+		var skipped json.RawMessage
+		if err := f.dec.Decode(&skipped); err != nil {
+			return count, err
+		}
+		count++
+	}
+	_, err := f.dec.Token()
+	return count, err
+}
+
+// jsonScalar formats a string, number, boolean or null token
+func jsonScalar(token json.Token) string {
+	switch value := token.(type) {
+	case nil:
+		return "null"
+	case string:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(value)
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return fmt.Sprint(token)
+}
+
+// xmlNode is an element of a parsed XML document
+type xmlNode struct {
+	Name     string
+	Attrs    []xml.Attr
+	Text     string
+	Children []*xmlNode
+}
+
+// child returns the first child element with the given local name
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// attr returns the value of the attribute with the given local name
+func (n *xmlNode) attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// parseXMLTree parses content into a tree of elements with whitespace-collapsed text
+func parseXMLTree(content string) (*xmlNode, error) {
+	dec := xml.NewDecoder(strings.NewReader(content))
+	dec.Strict = false
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		// Content is already transcoded to UTF-8 by the client
+		return input, nil
+	}
+
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: t.Name.Local, Attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				node := stack[len(stack)-1]
+				if text := strings.Join(strings.Fields(string(t)), " "); text != "" {
+					node.Text = strings.TrimSpace(node.Text + " " + text)
+				}
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// renderXMLNode prints an element and its children as a tree
+func (r *HTMLRenderer) renderXMLNode(node *xmlNode, prefix, childPrefix string) {
+	line := node.Name
+	for _, attr := range node.Attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		line += fmt.Sprintf(" [%s=%s]", attr.Name.Local, attr.Value)
+	}
+	if node.Text != "" {
+		line += ": " + node.Text
+	}
+	r.println(prefix + line)
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			r.renderXMLNode(child, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			r.renderXMLNode(child, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
+
+// feedDocument builds an HTML document with the title and item links of an
+// RSS or Atom feed, so the navigator can number them like page links
+func feedDocument(root *xmlNode) string {
+	channel := root
+	if root.Name == "rss" || root.Name == "RDF" {
+		if c := root.child("channel"); c != nil {
+			channel = c
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<html><head>")
+	if title := channel.child("title"); title != nil {
+		b.WriteString("<title>" + html.EscapeString(title.Text) + "</title>")
+	}
+	b.WriteString("</head><body>")
+
+	// RSS 1.0 keeps items next to the channel instead of inside it
+	items := append([]*xmlNode{}, channel.Children...)
+	if channel != root {
+		items = append(items, root.Children...)
+	}
+	for _, item := range items {
+		if item.Name != "item" && item.Name != "entry" {
+			continue
+		}
+		link := ""
+		for _, child := range item.Children {
+			if child.Name != "link" {
+				continue
+			}
+			if child.Text != "" {
+				link = child.Text
+			} else if rel := child.attr("rel"); rel == "" || rel == "alternate" {
+				link = child.attr("href")
+			}
+			if link != "" {
+				break
+			}
+		}
+		if link == "" {
+			continue
+		}
+		text := link
+		if title := item.child("title"); title != nil && title.Text != "" {
+			text = title.Text
+		}
+		b.WriteString("<p><a href=\"" + html.EscapeString(link) + "\">" + html.EscapeString(text) + "</a></p>")
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
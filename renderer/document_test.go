@@ -0,0 +1,120 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFormatJSON checks indentation, key order and folding by depth and by count
+func TestFormatJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		maxDepth int
+		maxItems int
+		want     string
+	}{
+		{
+			name: "key order and escapes",
+			data: `{"b":1,"a":[true,null,"<x>"],"e":{}}`,
+			want: "{\n  \"b\": 1,\n  \"a\": [\n    true,\n    null,\n    \"<x>\"\n  ],\n  \"e\": {}\n}",
+		},
+		{
+			name:     "folded by depth",
+			data:     `{"a":{"b":{"c":1,"d":2}},"l":[[1,2,3]]}`,
+			maxDepth: 2,
+			want:     "{\n  \"a\": {\n    \"b\": {…2 keys}\n  },\n  \"l\": [\n    […3 items]\n  ]\n}",
+		},
+		{
+			name:     "folded by count",
+			data:     `[1,2,3,4,5]`,
+			maxItems: 2,
+			want:     "[\n  1,\n  2,\n  … 3 more items\n]",
+		},
+		{
+			name: "json lines",
+			data: "{\"a\":1}\n{\"a\":2}\n",
+			want: "{\n  \"a\": 1\n}\n{\n  \"a\": 2\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatJSON([]byte(tt.data), tt.maxDepth, tt.maxItems)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("formatJSON:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := formatJSON([]byte(`{"a":`), 0, 0); err == nil {
+		t.Error("expected an error for truncated JSON")
+	}
+}
+
+// TestParseXMLTree checks that elements, attributes and collapsed text are kept
+func TestParseXMLTree(t *testing.T) {
+	root, err := parseXMLTree(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<catalog xmlns="urn:x"><book id="7">
+  <title>  Go   in
+  Practice </title><note/></book></catalog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := root.child("book")
+	if root.Name != "catalog" || book == nil || book.attr("id") != "7" || len(book.Children) != 2 {
+		t.Fatalf("unexpected tree: %+v", root)
+	}
+	if title := book.child("title"); title == nil || title.Text != "Go in Practice" {
+		t.Fatalf("unexpected title: %+v", title)
+	}
+
+	if _, err := parseXMLTree("just text"); err == nil {
+		t.Error("expected an error without a root element")
+	}
+}
+
+// TestFeedDocument checks that RSS, RSS 1.0 and Atom feeds become a page of item links
+func TestFeedDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		feed string
+		want string
+	}{
+		{
+			name: "rss",
+			feed: `<rss><channel><title>News &amp; more</title>` +
+				`<item><title>First</title><link>https://example.com/1</link></item>` +
+				`<item><link>https://example.com/2?a=1&amp;b=2</link></item>` +
+				`<item><title>No link</title></item></channel></rss>`,
+			want: `<title>News &amp; more</title></head><body>` +
+				`<p><a href="https://example.com/1">First</a></p>` +
+				`<p><a href="https://example.com/2?a=1&amp;b=2">https://example.com/2?a=1&amp;b=2</a></p></body>`,
+		},
+		{
+			name: "rss 1.0",
+			feed: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><channel><title>Old</title></channel>` +
+				`<item><title>Outside</title><link>https://example.com/o</link></item></rdf:RDF>`,
+			want: `<title>Old</title></head><body><p><a href="https://example.com/o">Outside</a></p></body>`,
+		},
+		{
+			name: "atom",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title><entry><title>Post</title>` +
+				`<link rel="edit" href="https://example.com/edit"/><link href="https://example.com/post"/></entry></feed>`,
+			want: `<title>Blog</title></head><body><p><a href="https://example.com/post">Post</a></p></body>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseXMLTree(tt.feed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := feedDocument(root); !strings.Contains(got, tt.want) {
+				t.Errorf("feedDocument = %s\nwant it to contain %s", got, tt.want)
+			}
+		})
+	}
+}
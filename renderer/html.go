@@ -1,10 +1,13 @@
 package renderer
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
+	"brauser/browser"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -12,15 +15,41 @@ import (
 type HTMLRenderer struct {
 	imageRenderer *ImageRenderer
 	outputBuffer  strings.Builder
+	jsonMaxDepth  int
+	jsonMaxItems  int
+	readerMode    bool
 }
 
 // NewHTMLRenderer creates a new HTML renderer
 func NewHTMLRenderer() *HTMLRenderer {
 	return &HTMLRenderer{
 		imageRenderer: NewImageRenderer(),
+		jsonMaxDepth:  DefaultJSONMaxDepth,
+		jsonMaxItems:  DefaultJSONMaxItems,
 	}
 }
 
+// SetHTTPClient sets the HTTP client used to download images
+func (r *HTMLRenderer) SetHTTPClient(client *http.Client) {
+	r.imageRenderer.SetHTTPClient(client)
+}
+
+// SetMaxImageSize sets the largest image that is downloaded for ASCII rendering
+func (r *HTMLRenderer) SetMaxImageSize(size int64) {
+	r.imageRenderer.SetMaxSize(size)
+}
+
+// SetReaderMode shows only the main content of articles, with byline, date
+// and lead image, instead of the whole page
+func (r *HTMLRenderer) SetReaderMode(enabled bool) {
+	r.readerMode = enabled
+}
+
+// ReaderMode reports whether reader mode is on
+func (r *HTMLRenderer) ReaderMode() bool {
+	return r.readerMode
+}
+
 // compressEmptyLines removes multiple consecutive empty lines and replaces them with single empty lines
 func (r *HTMLRenderer) compressEmptyLines(text string) string {
 	// Replace multiple consecutive newlines with double newlines (single empty line)
@@ -47,15 +76,40 @@ func (r *HTMLRenderer) flushOutput() {
 
 // RenderHTML parses the HTML content and displays it in a structured format
 func (r *HTMLRenderer) RenderHTML(htmlContent, baseURL string) (*goquery.Document, error) {
+	return r.RenderHTMLContext(context.Background(), htmlContent, baseURL)
+}
+
+// RenderHTMLContext is like RenderHTML but stops fetching images as soon as ctx is done
+func (r *HTMLRenderer) RenderHTMLContext(ctx context.Context, htmlContent, baseURL string) (*goquery.Document, error) {
+	return r.render(ctx, htmlContent, baseURL, nil)
+}
+
+// RenderPage renders a fetched page according to its content type, resolving
+// relative URLs against the final URL after redirects and showing the response
+// status above the content
+func (r *HTMLRenderer) RenderPage(ctx context.Context, page *browser.PageResult) (*goquery.Document, error) {
+	return r.renderContent(ctx, page.Content, page.ContentType, page.FinalURL, page)
+}
+
+// render parses and displays HTML content; page is nil when rendering bare HTML
+func (r *HTMLRenderer) render(ctx context.Context, htmlContent, baseURL string, page *browser.PageResult) (*goquery.Document, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
 	}
+	
+	// Number the links so they are shown as text[N], with the numbers the navigator follows
+	browser.NumberLinks(doc, baseURL)
 
 	r.println("\n" + strings.Repeat("=", 60))
 	r.println("           BRAUSER - TERMINAL WEB CONTENT")
 	r.println(strings.Repeat("=", 60))
 
+	// Show where the content came from and how the server answered
+	if page != nil {
+		r.renderPageInfo(page)
+	}
+
 	// Extract and print title
 	title := doc.Find("title").Text()
 	if title != "" {
@@ -63,101 +117,27 @@ func (r *HTMLRenderer) RenderHTML(htmlContent, baseURL string) (*goquery.Documen
 		r.println(strings.Repeat("-", len(title)+10))
 	}
 
-	// Extract and print headings with hierarchy
-	headingCount := 0
-	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
-		headingCount++
-		tagName := s.Get(0).Data
-		text := strings.TrimSpace(s.Text())
-		if text != "" {
-			switch tagName {
-			case "h1":
-				r.printf("\n🔸 %s\n", text)
-				r.println(strings.Repeat("=", len(text)))
-			case "h2":
-				r.printf("\n▸ %s\n", text)
-				r.println(strings.Repeat("-", len(text)))
-			default:
-				r.printf("\n• %s\n", text)
-			}
-		}
-	})
-
-	// Extract Hacker News stories (table-based layout)
-	doc.Find(".athing").Each(func(i int, s *goquery.Selection) {
-		if i >= 30 { // Limit to top 30 stories
-			return
-		}
-		headingCount++
-		
-		// Get story title and link
-		titleLink := s.Find(".titleline > a").First()
-		title := strings.TrimSpace(titleLink.Text())
-		if title != "" {
-			r.printf("\n📰 %s\n", title)
-		}
-	})
-
-	// Extract and print paragraphs with better formatting
-	paragraphCount := 0
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" && len(text) > 10 { // Filter out very short paragraphs
-			paragraphCount++
-			r.printf("\n%s\n", text)
+	// Reader mode shows only the article, when the page has one
+	if r.readerMode {
+		if article := browser.ExtractArticle(doc, baseURL); article != nil {
+			r.renderArticle(ctx, article, baseURL, title)
+			r.flushOutput()
+			return doc, nil
 		}
-	})
-
-	// Extract content from common container elements if no paragraphs found
-	if paragraphCount == 0 {
-		doc.Find(".content, .main, .post, .entry, .article-content, .story-content").Each(func(i int, s *goquery.Selection) {
-			text := strings.TrimSpace(s.Text())
-			if text != "" && len(text) > 50 {
-				paragraphCount++
-				r.printf("\n📝 CONTENT:\n%s\n", text[:min(300, len(text))])
-				if len(text) > 300 {
-					r.println("... (content truncated)")
-				}
-			}
-		})
+		r.println("\n📖 No article found on this page, showing the whole page")
 	}
 
-	// Extract and print main content areas
-	doc.Find("main, article, .content, .main-content, #content").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" && len(text) > 50 {
-			r.printf("\n📝 MAIN CONTENT:\n%s\n", text[:min(500, len(text))])
-			if len(text) > 500 {
-				r.println("... (content truncated)")
-			}
-		}
-	})
-
-	// Note: Link extraction and numbering is now handled by the Navigator
-	// This renderer focuses on content display only
-
-	// Note: Story extraction is now handled by the Navigator for interactive selection
-
-	// Extract lists
-	listCount := 0
-	doc.Find("ul li, ol li").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" && len(text) > 5 && listCount < 10 {
-			listCount++
-			if listCount == 1 {
-				r.println("\n📋 LIST ITEMS:")
-			}
-			r.printf("  • %s\n", text)
-		}
-	})
-
-	// Render images as ASCII art
-	r.renderImages(doc, baseURL)
+	// Walk the page once so content appears in the order a reader sees it
+	walker := r.newBlockWalker(ctx, baseURL)
+	for _, node := range doc.Find("body").Nodes {
+		walker.walk(node)
+	}
+	walker.flush()
 
 	// Summary
-	r.printf("\n" + strings.Repeat("=", 60))
-	r.printf("\n📊 CONTENT SUMMARY: %d headings, %d paragraphs\n", headingCount, paragraphCount)
-	r.println("💡 Use navigation menu to interact with links")
+	r.printf("\n%s", strings.Repeat("=", 60))
+	r.printf("\n📊 CONTENT SUMMARY: %d headings, %d paragraphs, %d list items, %d images\n", walker.headings, walker.paragraphs, walker.listItems, walker.images)
+	r.println("💡 Type the number after a link, like text[N], to follow it")
 	r.println(strings.Repeat("=", 60))
 
 	// Flush the buffered output with compressed empty lines
@@ -166,50 +146,81 @@ func (r *HTMLRenderer) RenderHTML(htmlContent, baseURL string) (*goquery.Documen
 	return doc, nil
 }
 
-// renderImages processes and renders all images in the document
-func (r *HTMLRenderer) renderImages(doc *goquery.Document, baseURL string) {
-	imageCount := 0
-	doc.Find("img").Each(func(i int, s *goquery.Selection) {
-		src, exists := s.Attr("src")
-		alt := s.AttrOr("alt", "")
-		if exists && imageCount < 5 { // Limit to 5 images to avoid spam
-			// Skip problematic image formats
-			if strings.HasSuffix(strings.ToLower(src), ".svg") ||
-			   strings.Contains(strings.ToLower(src), "1x1") ||
-			   strings.Contains(strings.ToLower(src), "pixel") {
-				return // Skip tracking pixels and SVGs
-			}
-			
-			imageCount++
-			if imageCount == 1 {
-				r.println("\n🖼️  IMAGES:")
-			}
-			r.printf("  Image %d: %s", imageCount, src)
-			if alt != "" {
-				r.printf(" (alt: %s)", alt)
-			}
-			r.println("")
-			
-			// Try to render as ASCII art
-			asciiArt, err := r.imageRenderer.RenderImageAsASCII(src, baseURL)
-			if err != nil {
-				if alt != "" {
-					r.printf("    [Image: %s]\n", alt)
-				} else {
-					r.printf("    [Image conversion failed: unsupported format]\n")
-				}
-			} else {
-				r.println("    ASCII Art:")
-				r.println(asciiArt)
-			}
+// renderPageInfo prints the final URL, redirect chain and response status of a fetched page
+func (r *HTMLRenderer) renderPageInfo(page *browser.PageResult) {
+	r.printf("\n🌐 %s\n", page.FinalURL)
+	for _, hop := range page.RedirectChain {
+		switch hop.Kind {
+		case browser.RedirectMetaRefresh:
+			r.printf("   ↪ meta refresh from %s\n", hop.From)
+		case browser.RedirectJavaScript:
+			r.printf("   ↪ JavaScript redirect from %s\n", hop.From)
+		default:
+			r.printf("   ↪ %d redirect from %s\n", hop.StatusCode, hop.From)
 		}
-	})
+	}
+	if len(page.RedirectChain) == 0 && page.FinalURL != page.URL {
+		r.printf("   (redirected from %s)\n", page.URL)
+	}
+	if page.IsSuccess() {
+		r.printf("✅ %s", page.Status)
+	} else {
+		r.printf("⚠️  %s", page.Status)
+	}
+	if page.ContentType != "" {
+		r.printf(" · %s", page.ContentType)
+	}
+	if page.ContentRewritten {
+		r.printf(" · processed by %s", page.SiteHandler)
+	}
+	switch page.CacheStatus {
+	case browser.CacheHit, browser.CacheOffline:
+		r.printf(" · 💾 from cache")
+	case browser.CacheRevalidated:
+		r.printf(" · 💾 from cache (revalidated)")
+	}
+	r.println("")
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// renderHeading prints a heading, underlined by level
+func (r *HTMLRenderer) renderHeading(tagName, text string) {
+	switch tagName {
+	case "h1":
+		r.printf("\n🔸 %s\n", text)
+		r.println(strings.Repeat("=", len(text)))
+	case "h2":
+		r.printf("\n▸ %s\n", text)
+		r.println(strings.Repeat("-", len(text)))
+	default:
+		r.printf("\n• %s\n", text)
 	}
-	return b
-}
\ No newline at end of file
+}
+
+// isDecorativeImage reports whether an image is a tracking pixel or an SVG,
+// which cannot be shown as ASCII art
+func isDecorativeImage(src string) bool {
+	src = strings.ToLower(src)
+	return strings.HasSuffix(src, ".svg") || strings.Contains(src, "1x1") || strings.Contains(src, "pixel")
+}
+
+// renderImage prints the source of an image and the image as ASCII art
+func (r *HTMLRenderer) renderImage(ctx context.Context, number int, src, alt, baseURL string) {
+	r.printf("  Image %d: %s", number, src)
+	if alt != "" {
+		r.printf(" (alt: %s)", alt)
+	}
+	r.println("")
+	
+	// Try to render as ASCII art
+	asciiArt, err := r.imageRenderer.RenderImageAsASCIIContext(ctx, src, baseURL)
+	if err != nil {
+		if alt != "" {
+			r.printf("    [Image: %s]\n", alt)
+		} else {
+			r.printf("    [Image conversion failed: unsupported format]\n")
+		}
+	} else {
+		r.println("    ASCII Art:")
+		r.println(asciiArt)
+	}
+}
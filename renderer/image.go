@@ -1,19 +1,22 @@
 package renderer
 
 import (
-	"io"
+	"context"
 	"net/http"
 	"net/url"
 	"os"
 
+	"brauser/browser"
 	aic "github.com/TheZoraiz/ascii-image-converter/aic_package"
 )
 
 // ImageRenderer handles conversion of images to ASCII art
 type ImageRenderer struct {
-	width  int
-	height int
-	colored bool
+	width      int
+	height     int
+	colored    bool
+	httpClient *http.Client
+	maxSize    int64
 }
 
 // NewImageRenderer creates a new image renderer with default settings
@@ -22,9 +25,21 @@ func NewImageRenderer() *ImageRenderer {
 		width:  80,
 		height: 40,
 		colored: true,
+		httpClient: http.DefaultClient,
+		maxSize: browser.DefaultMaxImageSize,
 	}
 }
 
+// SetHTTPClient sets the HTTP client used to download images
+func (ir *ImageRenderer) SetHTTPClient(client *http.Client) {
+	ir.httpClient = client
+}
+
+// SetMaxSize sets the largest decoded image that is downloaded; 0 disables the limit
+func (ir *ImageRenderer) SetMaxSize(size int64) {
+	ir.maxSize = size
+}
+
 // SetDimensions sets the ASCII art dimensions
 func (ir *ImageRenderer) SetDimensions(width, height int) {
 	ir.width = width
@@ -38,6 +53,11 @@ func (ir *ImageRenderer) SetColored(colored bool) {
 
 // RenderImageAsASCII fetches an image from the given src (handling relative URLs) and converts it to ASCII art
 func (ir *ImageRenderer) RenderImageAsASCII(src, baseURL string) (string, error) {
+	return ir.RenderImageAsASCIIContext(context.Background(), src, baseURL)
+}
+
+// RenderImageAsASCIIContext is like RenderImageAsASCII but aborts the image download as soon as ctx is done
+func (ir *ImageRenderer) RenderImageAsASCIIContext(ctx context.Context, src, baseURL string) (string, error) {
 	// Handle relative URLs
 	u, err := url.Parse(src)
 	if err != nil {
@@ -50,36 +70,68 @@ func (ir *ImageRenderer) RenderImageAsASCII(src, baseURL string) (string, error)
 		}
 		src = base.ResolveReference(u).String()
 	}
+	if err := browser.CheckEmbedded(baseURL, src); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return "", err
+	}
 
-	resp, err := http.Get(src)
+	resp, err := ir.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	imgData, err := io.ReadAll(resp.Body)
+	// Stream the image to disk so oversized images are cut off without filling memory
+	if err := browser.CheckContentLength(resp, ir.maxSize); err != nil {
+		return "", err
+	}
+	tempFile, err := os.CreateTemp("", "brauser-img-*.tmp")
 	if err != nil {
 		return "", err
 	}
+	defer os.Remove(tempFile.Name())
 
+	if _, err := browser.CopyLimited(tempFile, resp.Body, ir.maxSize, src); err != nil {
+		tempFile.Close()
+		return "", err
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", err
+	}
+
+	return ir.convertFile(tempFile.Name())
+}
+
+// RenderImageData converts an image that is already in memory, such as a page that is an image, to ASCII art
+func (ir *ImageRenderer) RenderImageData(data []byte) (string, error) {
 	tempFile, err := os.CreateTemp("", "brauser-img-*.tmp")
 	if err != nil {
 		return "", err
 	}
 	defer os.Remove(tempFile.Name())
 
-	if _, err := tempFile.Write(imgData); err != nil {
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
 		return "", err
 	}
 	if err := tempFile.Close(); err != nil {
 		return "", err
 	}
 
+	return ir.convertFile(tempFile.Name())
+}
+
+// convertFile converts the image file at path to ASCII art
+func (ir *ImageRenderer) convertFile(path string) (string, error) {
 	flags := aic.DefaultFlags()
 	flags.Dimensions = []int{ir.width, ir.height}
 	flags.Colored = ir.colored
 
-	asciiArt, err := aic.Convert(tempFile.Name(), flags)
+	asciiArt, err := aic.Convert(path, flags)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,37 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"brauser/browser"
+)
+
+// TestImageFromRemotePage checks that a remote page cannot make the renderer
+// read a local file as an image, while local pages can
+func TestImageFromRemotePage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dot.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, image.NewGray(image.Rect(0, 0, 4, 4)))
+	file.Close()
+
+	renderer := NewImageRenderer()
+	renderer.SetHTTPClient(browser.NewClient().HTTPClient())
+	renderer.SetDimensions(4, 2)
+
+	var violation *browser.PolicyViolationError
+	if _, err := renderer.RenderImageAsASCIIContext(context.Background(), "file://"+path, "https://example.com/"); !errors.As(err, &violation) {
+		t.Fatalf("expected a policy violation, got %v", err)
+	}
+	if _, err := renderer.RenderImageAsASCIIContext(context.Background(), "file://"+path, "file:///tmp/page.html"); err != nil {
+		t.Fatalf("local page image: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package renderer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"brauser/browser"
+	"golang.org/x/net/html"
+)
+
+// renderArticle prints the main content of a page found by reader mode: the
+// byline, date and lead image, then the article body in document order. The
+// article title is left out when it repeats the page title already shown.
+func (r *HTMLRenderer) renderArticle(ctx context.Context, article *browser.Article, baseURL, pageTitle string) {
+	r.println("\n📖 READER VIEW")
+	if article.Title != "" && article.Title != collapseText(pageTitle) {
+		r.renderHeading("h1", article.Title)
+	}
+
+	var details []string
+	if article.Byline != "" {
+		details = append(details, "✍️  "+article.Byline)
+	}
+	if article.Published != "" {
+		details = append(details, "🗓  "+formatPublished(article.Published))
+	}
+	if article.SiteName != "" {
+		details = append(details, "🏠 "+article.SiteName)
+	}
+	if len(details) > 0 {
+		r.println(strings.Join(details, " · "))
+	}
+
+	walker := r.newBlockWalker(ctx, baseURL)
+	if article.LeadImage != "" && !containsImage(article.Content, article.LeadImage, baseURL) {
+		walker.images++
+		r.println("")
+		r.renderImage(ctx, walker.images, article.LeadImage, "lead image", baseURL)
+	}
+	for _, node := range article.Content {
+		if ctx.Err() != nil {
+			break
+		}
+		// A heading repeating the title was already shown
+		if isHeading(node) && collapseText(nodeText(node)) == article.Title {
+			continue
+		}
+		walker.walk(node)
+	}
+	walker.flush()
+
+	words := 0
+	for _, node := range article.Content {
+		words += len(strings.Fields(nodeText(node)))
+	}
+	r.printf("\n%s", strings.Repeat("=", 60))
+	r.printf("\n📊 CONTENT SUMMARY: reader view, %d words, %d headings, %d paragraphs, %d list items, %d images\n", words, walker.headings, walker.paragraphs, walker.listItems, walker.images)
+	r.println("💡 Type 'reader' to show the whole page")
+	r.println(strings.Repeat("=", 60))
+}
+
+// formatPublished shows an ISO 8601 publication date as a date and time, and
+// anything else as given
+func formatPublished(published string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, published); err == nil {
+			if t.Hour() == 0 && t.Minute() == 0 {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04")
+		}
+	}
+	return published
+}
+
+// containsImage reports whether one of the nodes holds an image with the given
+// absolute URL
+func containsImage(nodes []*html.Node, imageURL, baseURL string) bool {
+	var found bool
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if found {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "img" {
+			if src := htmlAttr(n, "src"); src != "" && resolveURL(src, baseURL) == imageURL {
+				found = true
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	for _, node := range nodes {
+		visit(node)
+	}
+	return found
+}
+
+// isHeading reports whether a node is an h1 to h6 element
+func isHeading(n *html.Node) bool {
+	return n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6'
+}
@@ -0,0 +1,745 @@
+package renderer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"brauser/browser"
+	"golang.org/x/net/html"
+)
+
+// Table layout limits; a column is never narrower than its longest word up to
+// tableMinColumnWidth, and tables that cannot fit their columns at that width
+// are shown one record per row
+const (
+	tableMinColumnWidth = 10
+	tableMaxSpan        = 100
+)
+
+// tableCell is a td or th of a table, possibly spanning several slots of the grid
+type tableCell struct {
+	lines  []string // Text of the cell, one entry per forced line break
+	header bool
+	align  string // "left", "right" or "center"
+	row    int    // Row and column of the top left slot
+	col    int
+	rows   int // Number of rows and columns the cell spans
+	cols   int
+	output []string // Lines of the cell wrapped to the column width
+}
+
+// tableLayout is a table as a grid of slots, each covered by one cell
+type tableLayout struct {
+	caption string
+	grid    [][]*tableCell
+	cells   []*tableCell
+	headers int // Number of leading rows that are table headers
+}
+
+// table renders a table element: data tables as a grid with box borders fitted
+// to the text width, tables used for page layout as flowing text
+func (w *blockWalker) table(n *html.Node) {
+	if isLayoutTable(n) {
+		w.children(n)
+		w.flush()
+		return
+	}
+	layout := w.tableLayout(n)
+	if len(layout.grid) == 0 {
+		return
+	}
+	if w.tight == 0 {
+		w.r.println("")
+	}
+	if layout.caption != "" {
+		for _, line := range wrapText(layout.caption, w.width-utf8.RuneCountInString(w.prefix)) {
+			w.r.println(w.prefix + line)
+		}
+	}
+
+	width := w.width - utf8.RuneCountInString(w.prefix)
+	lines, ok := layout.grid2D(width)
+	if !ok {
+		lines = layout.records(width)
+	}
+	for _, line := range lines {
+		w.r.println(w.prefix + line)
+	}
+	w.paragraphs++
+}
+
+// isLayoutTable reports whether a table arranges the page rather than holding
+// data: it is marked as presentation, holds other tables or has a single column
+func isLayoutTable(n *html.Node) bool {
+	if role := htmlAttr(n, "role"); role == "presentation" || role == "none" {
+		return true
+	}
+	if hasElement(n, "table") {
+		return true
+	}
+	columns := 0
+	for _, row := range tableRows(n) {
+		count := 0
+		for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+				count += spanAttr(cell, "colspan")
+			}
+		}
+		columns = max(columns, count)
+	}
+	return columns <= 1
+}
+
+// tableRows returns the rows of a table in order, without those of nested tables
+func tableRows(n *html.Node) []*html.Node {
+	var rows []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		switch child.Data {
+		case "tr":
+			rows = append(rows, child)
+		case "thead", "tbody", "tfoot":
+			rows = append(rows, tableRows(child)...)
+		}
+	}
+	return rows
+}
+
+// tableLayout places the cells of a table on a grid, honoring colspan and
+// rowspan, and drops rows and columns that have no content
+func (w *blockWalker) tableLayout(n *html.Node) *tableLayout {
+	layout := &tableLayout{}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "caption" {
+			layout.caption = collapseText(nodeText(child))
+		}
+	}
+
+	rows := tableRows(n)
+	var grid [][]*tableCell
+	for r, row := range rows {
+		for len(grid) <= r {
+			grid = append(grid, nil)
+		}
+		col := 0
+		for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+				continue
+			}
+			for col < len(grid[r]) && grid[r][col] != nil {
+				col++
+			}
+			c := &tableCell{
+				lines:  w.cellLines(cell),
+				header: cell.Data == "th" || row.Parent != nil && row.Parent.Data == "thead",
+				align:  cellAlign(cell, row),
+				cols:   spanAttr(cell, "colspan"),
+				rows:   spanAttr(cell, "rowspan"),
+			}
+			// rowspan="0" spans the remaining rows
+			if htmlAttr(cell, "rowspan") == "0" {
+				c.rows = len(rows) - r
+			}
+			c.rows = min(c.rows, len(rows)-r)
+			for dr := 0; dr < c.rows; dr++ {
+				for len(grid[r+dr]) < col+c.cols {
+					grid[r+dr] = append(grid[r+dr], nil)
+				}
+				for dc := 0; dc < c.cols; dc++ {
+					grid[r+dr][col+dc] = c
+				}
+				if dr+1 < c.rows && len(grid) <= r+dr+1 {
+					grid = append(grid, nil)
+				}
+			}
+			col += c.cols
+		}
+	}
+
+	// Give every slot no cell covers an empty cell: the ends of short rows and
+	// the gaps left next to cells spanning rows
+	columns := 0
+	for _, row := range grid {
+		columns = max(columns, len(row))
+	}
+	for r := range grid {
+		for len(grid[r]) < columns {
+			grid[r] = append(grid[r], nil)
+		}
+		for c := range grid[r] {
+			if grid[r][c] == nil {
+				grid[r][c] = &tableCell{rows: 1, cols: 1}
+			}
+		}
+	}
+
+	layout.grid = pruneGrid(grid)
+	layout.index()
+	return layout
+}
+
+// pruneGrid drops rows without text and columns whose cells are all empty
+func pruneGrid(grid [][]*tableCell) [][]*tableCell {
+	var rows [][]*tableCell
+	for _, row := range grid {
+		for _, cell := range row {
+			if !cell.empty() {
+				rows = append(rows, row)
+				break
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keep := make([]bool, len(rows[0]))
+	for _, row := range rows {
+		for c, cell := range row {
+			if !cell.empty() {
+				keep[c] = true
+			}
+		}
+	}
+	for r, row := range rows {
+		var kept []*tableCell
+		for c, cell := range row {
+			if keep[c] {
+				kept = append(kept, cell)
+			}
+		}
+		rows[r] = kept
+	}
+	return rows
+}
+
+// index finds the position and span of every cell after the grid was pruned,
+// and counts the header rows at the top
+func (t *tableLayout) index() {
+	t.cells = nil
+	seen := map[*tableCell]bool{}
+	for r, row := range t.grid {
+		for c, cell := range row {
+			if seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			cell.row, cell.col, cell.rows, cell.cols = r, c, 0, 0
+			for rr := r; rr < len(t.grid) && t.grid[rr][c] == cell; rr++ {
+				cell.rows++
+			}
+			for cc := c; cc < len(row) && row[cc] == cell; cc++ {
+				cell.cols++
+			}
+			t.cells = append(t.cells, cell)
+		}
+	}
+
+	t.headers = 0
+	for _, row := range t.grid {
+		header := true
+		for _, cell := range row {
+			if !cell.header && !cell.empty() {
+				header = false
+			}
+		}
+		if !header || t.headers+1 == len(t.grid) {
+			break
+		}
+		t.headers++
+	}
+}
+
+// grid2D lays the table out with box borders in at most width columns; it
+// reports false when the columns do not fit even at their minimum widths
+func (t *tableLayout) grid2D(width int) ([]string, bool) {
+	columns := len(t.grid[0])
+	available := width - 3*columns - 1
+	minimum := make([]int, columns)
+	maximum := make([]int, columns)
+	for c := range minimum {
+		minimum[c], maximum[c] = 1, 1
+	}
+
+	// Cells spanning one column set the widths first, spanning cells widen
+	// the columns they cover when they need more room
+	for pass := 0; pass < 2; pass++ {
+		for _, cell := range t.cells {
+			if (cell.cols == 1) != (pass == 0) {
+				continue
+			}
+			low, high := cell.widths()
+			span := 3 * (cell.cols - 1)
+			spanMin, spanMax := span, span
+			for c := cell.col; c < cell.col+cell.cols; c++ {
+				spanMin += minimum[c]
+				spanMax += maximum[c]
+			}
+			for c := cell.col; c < cell.col+cell.cols; c++ {
+				minimum[c] += divide(low-spanMin, cell.cols, c-cell.col)
+				maximum[c] += divide(high-spanMax, cell.cols, c-cell.col)
+				maximum[c] = max(maximum[c], minimum[c])
+			}
+		}
+	}
+
+	total := 0
+	for _, m := range minimum {
+		total += m
+	}
+	if total > available {
+		return nil, false
+	}
+	widths := fitColumns(minimum, maximum, available)
+
+	// Wrap every cell to the width of the columns it covers
+	wrapped := false
+	for _, cell := range t.cells {
+		cell.output = nil
+		for _, line := range cell.lines {
+			cell.output = append(cell.output, wrapCell(line, cell.span(widths))...)
+		}
+		if len(cell.output) > 1 || cell.rows > 1 {
+			wrapped = true
+		}
+	}
+
+	// Rows are separated by rules after the headers, and between all rows
+	// when cells take more than one line
+	rules := make([]bool, len(t.grid)+1)
+	rules[0], rules[len(t.grid)] = true, true
+	for r := 1; r < len(t.grid); r++ {
+		rules[r] = wrapped || r == t.headers
+	}
+
+	// Rows are as high as their tallest cell; cells spanning rows grow the
+	// last row they cover
+	heights := make([]int, len(t.grid))
+	for r := range heights {
+		heights[r] = 1
+	}
+	for _, cell := range t.cells {
+		if cell.rows == 1 {
+			heights[cell.row] = max(heights[cell.row], len(cell.output))
+		}
+	}
+	for _, cell := range t.cells {
+		if cell.rows == 1 {
+			continue
+		}
+		room := 0
+		for r := cell.row; r < cell.row+cell.rows; r++ {
+			room += heights[r]
+			if r > cell.row && rules[r] {
+				room++
+			}
+		}
+		if need := len(cell.output) - room; need > 0 {
+			heights[cell.row+cell.rows-1] += need
+		}
+	}
+
+	// Every line of output is either a rule below row r-1 or a line of row r;
+	// top records where each cell starts so spanning cells flow across rules
+	top := map[*tableCell]int{}
+	var lines []string
+	y := 0
+	for r := 0; r <= len(t.grid); r++ {
+		if rules[r] {
+			lines = append(lines, t.rule(r, widths, y, top))
+			y++
+		}
+		if r == len(t.grid) {
+			break
+		}
+		for _, cell := range t.grid[r] {
+			if cell.row == r {
+				if _, ok := top[cell]; !ok {
+					top[cell] = y
+				}
+			}
+		}
+		for i := 0; i < heights[r]; i++ {
+			lines = append(lines, t.contentLine(r, widths, y, top))
+			y++
+		}
+	}
+	return lines, true
+}
+
+// rule draws the border above row r, where y is the line of output; parts
+// crossed by a cell spanning rows show that cell's text instead
+func (t *tableLayout) rule(r int, widths []int, y int, top map[*tableCell]int) string {
+	var line strings.Builder
+	above := func(c int) *tableCell {
+		if r == 0 {
+			return nil
+		}
+		return t.grid[r-1][c]
+	}
+	below := func(c int) *tableCell {
+		if r == len(t.grid) {
+			return nil
+		}
+		return t.grid[r][c]
+	}
+	// vertical reports whether a border runs between column c-1 and c in a row
+	vertical := func(cell func(int) *tableCell, c int) bool {
+		if r == 0 && cell(0) == nil || r == len(t.grid) && cell(0) == nil {
+			return false
+		}
+		return c == 0 || c == len(widths) || cell(c-1) != cell(c)
+	}
+
+	columns := len(widths)
+	previousText := false
+	for c := 0; c < columns; {
+		// A cell spanning the rule continues with its text
+		text := r > 0 && r < len(t.grid) && above(c) == below(c)
+		line.WriteString(junction(vertical(above, c), vertical(below, c), c > 0 && !previousText, !text))
+		if text {
+			cell := below(c)
+			line.WriteString(" " + cell.lineAt(y-top[cell], cell.span(widths)) + " ")
+			c += cell.cols - (c - cell.col)
+		} else {
+			line.WriteString(strings.Repeat("─", widths[c]+2))
+			c++
+		}
+		previousText = text
+	}
+	line.WriteString(junction(vertical(above, columns), vertical(below, columns), !previousText, false))
+	return line.String()
+}
+
+// contentLine draws one line of the cells of row r
+func (t *tableLayout) contentLine(r int, widths []int, y int, top map[*tableCell]int) string {
+	var line strings.Builder
+	for c := 0; c < len(widths); {
+		cell := t.grid[r][c]
+		line.WriteString("│ " + cell.lineAt(y-top[cell], cell.span(widths)) + " ")
+		c = cell.col + cell.cols
+	}
+	line.WriteString("│")
+	return line.String()
+}
+
+// records shows a table too wide for the terminal as one block per row with
+// the column header in front of every value
+func (t *tableLayout) records(width int) []string {
+	columns := len(t.grid[0])
+	names := make([]string, columns)
+	for c := range names {
+		var parts []string
+		for r := 0; r < t.headers; r++ {
+			if text := t.grid[r][c].text(); text != "" && (len(parts) == 0 || parts[len(parts)-1] != text) {
+				parts = append(parts, text)
+			}
+		}
+		names[c] = strings.Join(parts, " / ")
+		if names[c] == "" {
+			names[c] = fmt.Sprintf("Column %d", c+1)
+		}
+	}
+
+	var lines []string
+	for r := t.headers; r < len(t.grid); r++ {
+		lines = append(lines, fmt.Sprintf("── Row %d ──", r-t.headers+1))
+		for c := 0; c < columns; c++ {
+			cell := t.grid[r][c]
+			if cell.col != c || cell.empty() {
+				continue // Cells spanning columns are shown once
+			}
+			label := names[c] + ": "
+			indent := strings.Repeat(" ", min(utf8.RuneCountInString(label), width/3))
+			first := true
+			for _, text := range cell.lines {
+				for _, wrapped := range wrapCell(text, width-utf8.RuneCountInString(indent)-2) {
+					if first {
+						lines = append(lines, "  "+label+wrapped)
+						first = false
+					} else {
+						lines = append(lines, "  "+indent+wrapped)
+					}
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// cellLines returns the text of a table cell, one entry per line break or block
+func (w *blockWalker) cellLines(n *html.Node) []string {
+	var lines []string
+	var line strings.Builder
+	end := func() {
+		if text := collapseText(line.String()); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		if browser.IsHidden(n) {
+			return
+		}
+		switch n.Data {
+		case "script", "style", "noscript", "template", "svg", "select", "button", "input", "textarea":
+			return
+		case "br":
+			end()
+			return
+		case "img":
+			if alt := collapseText(htmlAttr(n, "alt")); alt != "" {
+				line.WriteString(" [" + alt + "] ")
+			}
+			return
+		case "p", "div", "ul", "ol", "li", "dl", "dt", "dd", "pre", "blockquote", "table", "tr",
+			"h1", "h2", "h3", "h4", "h5", "h6", "section", "article", "header", "footer":
+			end()
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				visit(child)
+			}
+			end()
+			return
+		case "a":
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				visit(child)
+			}
+			if number := htmlAttr(n, browser.LinkNumberAttr); number != "" {
+				text := line.String()
+				trimmed := strings.TrimRight(text, " \t\r\n")
+				line.Reset()
+				line.WriteString(trimmed + "[" + number + "]" + text[len(trimmed):])
+			}
+			return
+		case "td", "th":
+			line.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		visit(child)
+	}
+	end()
+	return lines
+}
+
+// text returns the text of a cell on one line
+func (c *tableCell) text() string {
+	return strings.Join(c.lines, " ")
+}
+
+// empty reports whether a cell has no text
+func (c *tableCell) empty() bool {
+	return len(c.lines) == 0
+}
+
+// widths returns the narrowest a cell can be, its longest word up to
+// tableMinColumnWidth, and the width it needs to fit on one line per break
+func (c *tableCell) widths() (int, int) {
+	low, high := 1, 1
+	for _, line := range c.lines {
+		high = max(high, utf8.RuneCountInString(line))
+		for _, word := range strings.Fields(line) {
+			low = max(low, min(utf8.RuneCountInString(word), tableMinColumnWidth))
+		}
+	}
+	return low, high
+}
+
+// span returns the width of the text of a cell from the widths of its columns
+func (c *tableCell) span(widths []int) int {
+	width := 3 * (c.cols - 1)
+	for col := c.col; col < c.col+c.cols; col++ {
+		width += widths[col]
+	}
+	return width
+}
+
+// lineAt returns line i of the wrapped cell, aligned and padded to width
+func (c *tableCell) lineAt(i, width int) string {
+	text := ""
+	if i >= 0 && i < len(c.output) {
+		text = c.output[i]
+	}
+	padding := max(0, width-utf8.RuneCountInString(text))
+	switch c.align {
+	case "right":
+		return strings.Repeat(" ", padding) + text
+	case "center":
+		return strings.Repeat(" ", padding/2) + text + strings.Repeat(" ", padding-padding/2)
+	}
+	return text + strings.Repeat(" ", padding)
+}
+
+// fitColumns picks column widths between their minimum and maximum that add up
+// to at most available, giving extra room to the columns that need it most
+func fitColumns(minimum, maximum []int, available int) []int {
+	widths := append([]int(nil), minimum...)
+	extra := available
+	wanted := 0
+	for c := range widths {
+		extra -= minimum[c]
+		wanted += maximum[c] - minimum[c]
+	}
+	if wanted <= extra {
+		return append([]int(nil), maximum...)
+	}
+	given := 0
+	for c := range widths {
+		share := (maximum[c] - minimum[c]) * extra / wanted
+		widths[c] += share
+		given += share
+	}
+	// Hand out what rounding left over, one column at a time
+	for extra -= given; extra > 0; {
+		best := -1
+		for c := range widths {
+			if widths[c] < maximum[c] && (best < 0 || maximum[c]-widths[c] > maximum[best]-widths[best]) {
+				best = c
+			}
+		}
+		if best < 0 {
+			break
+		}
+		widths[best]++
+		extra--
+	}
+	return widths
+}
+
+// divide splits a positive amount over n parts and returns part i
+func divide(amount, n, i int) int {
+	if amount <= 0 {
+		return 0
+	}
+	share := amount / n
+	if i < amount%n {
+		share++
+	}
+	return share
+}
+
+// junction returns the box drawing character joining borders that run up,
+// down, left and right of a point
+func junction(up, down, left, right bool) string {
+	switch {
+	case up && down && left && right:
+		return "┼"
+	case up && down && left:
+		return "┤"
+	case up && down && right:
+		return "├"
+	case up && down:
+		return "│"
+	case left && right && down:
+		return "┬"
+	case left && right && up:
+		return "┴"
+	case left && right:
+		return "─"
+	case down && right:
+		return "┌"
+	case down && left:
+		return "┐"
+	case up && right:
+		return "└"
+	case up && left:
+		return "┘"
+	case up || down:
+		return "│"
+	case left || right:
+		return "─"
+	}
+	return " "
+}
+
+// wrapCell wraps the text of a cell to width, breaking words that are longer
+func wrapCell(text string, width int) []string {
+	width = max(width, 1)
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		for len(runes) > width {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// cellAlign returns the alignment of a cell from its align attribute or style,
+// or from the row's; numbers are aligned right
+func cellAlign(cell, row *html.Node) string {
+	for _, n := range []*html.Node{cell, row} {
+		if align := strings.ToLower(htmlAttr(n, "align")); align == "right" || align == "center" || align == "left" {
+			return align
+		}
+		style := strings.ReplaceAll(strings.ToLower(htmlAttr(n, "style")), " ", "")
+		for _, align := range []string{"right", "center", "left"} {
+			if strings.Contains(style, "text-align:"+align) {
+				return align
+			}
+		}
+	}
+	if text := collapseText(nodeText(cell)); text != "" && isNumeric(text) {
+		return "right"
+	}
+	return "left"
+}
+
+// isNumeric reports whether text is a number, possibly with a sign, currency,
+// unit or percent sign
+func isNumeric(text string) bool {
+	digits := 0
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case strings.ContainsRune(" .,+-−%$€£¥'", r):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// spanAttr returns a colspan or rowspan attribute, 1 when it is missing or invalid
+func spanAttr(n *html.Node, name string) int {
+	span, err := strconv.Atoi(strings.TrimSpace(htmlAttr(n, name)))
+	if err != nil || span < 1 {
+		return 1
+	}
+	return min(span, tableMaxSpan)
+}
@@ -0,0 +1,90 @@
+package renderer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// renderWalked renders an HTML fragment with the block walker at the given width
+func renderWalked(t *testing.T, fragment string, width int) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(fragment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewHTMLRenderer()
+	w := r.newBlockWalker(context.Background(), "https://example.com/")
+	w.width = width
+	w.walk(doc)
+	w.flush()
+	return strings.TrimPrefix(r.outputBuffer.String(), "\n")
+}
+
+// TestTableLayout checks spans, ragged rows, wrapping and the record view of
+// tables too wide for the text width
+func TestTableLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		width    int
+		fragment string
+		want     string
+	}{
+		{"rowspan next to a short row", 80,
+			`<table><tr><td>a</td><td>b</td><td rowspan=2>c</td></tr><tr><td>x</td></tr></table>`, `
+┌───┬───┬───┐
+│ a │ b │ c │
+├───┼───┤   │
+│ x │   │   │
+└───┴───┴───┘
+`},
+		{"ragged rows", 80,
+			`<table><tr><td>a</td><td>b</td><td>c</td></tr><tr><td>d</td></tr></table>`, `
+┌───┬───┬───┐
+│ a │ b │ c │
+│ d │   │   │
+└───┴───┴───┘
+`},
+		{"rowspan and colspan", 80,
+			`<table><tr><th>Name</th><th>Qty</th></tr><tr><td rowspan="2">Apples</td><td>3</td></tr><tr><td>12</td></tr><tr><td colspan="2">Total 15</td></tr></table>`, `
+┌────────┬─────┐
+│ Name   │ Qty │
+├────────┼─────┤
+│ Apples │   3 │
+│        ├─────┤
+│        │  12 │
+├────────┴─────┤
+│ Total 15     │
+└──────────────┘
+`},
+		{"wrapped cells", 30,
+			`<table><tr><th>Key</th><th>Description</th></tr><tr><td>one</td><td>A fairly long description that has to wrap</td></tr></table>`, `
+┌─────┬──────────────────────┐
+│ Key │ Description          │
+├─────┼──────────────────────┤
+│ one │ A fairly long        │
+│     │ description that has │
+│     │ to wrap              │
+└─────┴──────────────────────┘
+`},
+		{"record view", 40,
+			`<table><tr><th>Alpha</th><th>Bravo</th><th>Charlie</th><th>Delta</th></tr><tr><td>alphabetical</td><td>bravissimo</td><td>charleston</td><td>deltaforce</td></tr></table>`, `
+── Row 1 ──
+  Alpha: alphabetical
+  Bravo: bravissimo
+  Charlie: charleston
+  Delta: deltaforce
+`},
+	}
+	for _, test := range tests {
+		if got := renderWalked(t, test.fragment, test.width); got != strings.TrimPrefix(test.want, "\n") {
+			t.Errorf("%s:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+
+	if widths := fitColumns([]int{3, 5, 4}, []int{3, 40, 20}, 30); widths[0] != 3 || widths[0]+widths[1]+widths[2] != 30 || widths[1] <= widths[2] {
+		t.Errorf("unexpected column widths %v", widths)
+	}
+}
@@ -0,0 +1,446 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"brauser/browser"
+	"golang.org/x/net/html"
+)
+
+// defaultTextWidth is the line width used when the terminal width is unknown
+const defaultTextWidth = 80
+
+// textWidth returns the width text is wrapped to, from $COLUMNS if set
+func textWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns >= 40 {
+		return columns
+	}
+	return defaultTextWidth
+}
+
+// blockWalker renders DOM nodes as text in the order a reader sees them.
+// Inline content is collected into paragraphs that are wrapped to the text
+// width; block elements end the current paragraph.
+type blockWalker struct {
+	r          *HTMLRenderer
+	ctx        context.Context
+	baseURL    string
+	width      int
+	prefix     string          // Indentation and quote marks of continuation lines
+	marker     string          // Prefix of the next first line, such as a list bullet
+	tight      int             // Inside lists, blocks are not separated by blank lines
+	cells      int             // Inside table cells, blocks do not break the row
+	inline     strings.Builder // Text of the paragraph being collected
+	headings   int
+	paragraphs int
+	listItems  int
+	images     int
+}
+
+// newBlockWalker creates a walker writing to the renderer's output buffer
+func (r *HTMLRenderer) newBlockWalker(ctx context.Context, baseURL string) *blockWalker {
+	return &blockWalker{r: r, ctx: ctx, baseURL: baseURL, width: textWidth()}
+}
+
+// walk renders a node and its children
+func (w *blockWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.DocumentNode:
+		w.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if browser.IsHidden(n) {
+		return
+	}
+	switch n.Data {
+	case "script", "style", "noscript", "template", "head", "svg", "iframe", "object",
+		"select", "option", "datalist", "button", "input", "textarea":
+		return
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.flush()
+		if text := collapseText(linkedText(n)); text != "" {
+			w.headings++
+			w.r.renderHeading(n.Data, text)
+		}
+	case "a":
+		w.children(n)
+		if number := htmlAttr(n, browser.LinkNumberAttr); number != "" {
+			// Image links are named by the image's alt text
+			if collapseText(nodeText(n)) == "" {
+				w.text(imageAlt(n))
+			}
+			w.linkMarker(number)
+		}
+	case "br":
+		w.inline.WriteString("\n")
+	case "hr":
+		w.flush()
+		w.r.println("")
+		w.r.println(w.prefix + strings.Repeat("─", min(40, w.width-utf8.RuneCountInString(w.prefix))))
+	case "pre":
+		w.flush()
+		w.preformatted(linkedText(n))
+	case "blockquote":
+		w.flush()
+		w.nested(w.prefix+"│ ", func() { w.children(n) })
+	case "ul", "ol":
+		w.flush()
+		w.list(n)
+	case "dl":
+		w.flush()
+		w.definitions(n)
+	case "img":
+		w.flush()
+		w.image(n)
+	case "table":
+		w.flush()
+		w.table(n)
+	case "tr":
+		w.flush()
+		w.children(n)
+		w.flush()
+	case "td", "th":
+		if collapseText(nodeText(n)) == "" && !hasElement(n, "img") {
+			return
+		}
+		if w.inline.Len() > 0 {
+			w.inline.WriteString(" | ")
+		}
+		w.cells++
+		w.children(n)
+		w.cells--
+	case "p", "div", "section", "article", "main", "header", "footer", "nav", "aside",
+		"figure", "figcaption", "address", "details", "summary", "center", "form", "fieldset",
+		"li", "dt", "dd", "caption", "tbody", "thead", "tfoot":
+		if w.cells > 0 {
+			w.text(" ")
+			w.children(n)
+			w.text(" ")
+			return
+		}
+		w.flush()
+		w.children(n)
+		w.flush()
+	default:
+		w.children(n)
+	}
+}
+
+// children renders the children of a node in order
+func (w *blockWalker) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if w.ctx.Err() != nil {
+			return
+		}
+		w.walk(child)
+	}
+}
+
+// text adds text to the current paragraph, collapsing white space like a browser
+func (w *blockWalker) text(data string) {
+	if data == "" {
+		return
+	}
+	current := w.inline.String()
+	leadingSpace := strings.TrimLeft(data, " \t\r\n\f") != data
+	words := strings.Fields(data)
+	if len(words) == 0 {
+		if current != "" && !strings.HasSuffix(current, " ") && !strings.HasSuffix(current, "\n") {
+			w.inline.WriteString(" ")
+		}
+		return
+	}
+	if leadingSpace && current != "" && !strings.HasSuffix(current, " ") && !strings.HasSuffix(current, "\n") {
+		w.inline.WriteString(" ")
+	}
+	w.inline.WriteString(strings.Join(words, " "))
+	if strings.TrimRight(data, " \t\r\n\f") != data {
+		w.inline.WriteString(" ")
+	}
+}
+
+// flush writes the collected paragraph, wrapped to the text width
+func (w *blockWalker) flush() {
+	text := w.inline.String()
+	w.inline.Reset()
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	if w.tight == 0 {
+		w.paragraphs++
+		w.r.println("")
+	}
+	first := w.takeMarker()
+	for i, line := range lines {
+		prefix := w.prefix
+		if i == 0 {
+			prefix = first
+		}
+		for j, wrapped := range wrapText(line, w.width-utf8.RuneCountInString(w.prefix)) {
+			if j > 0 {
+				prefix = w.prefix
+			}
+			w.r.println(prefix + wrapped)
+		}
+	}
+}
+
+// takeMarker returns the prefix for the next first line and clears the marker
+func (w *blockWalker) takeMarker() string {
+	if w.marker == "" {
+		return w.prefix
+	}
+	marker := w.marker
+	w.marker = ""
+	return marker
+}
+
+// linkMarker puts the number of a link right after its text, like text[12]
+func (w *blockWalker) linkMarker(number string) {
+	text := w.inline.String()
+	trimmed := strings.TrimRight(text, " ")
+	w.inline.Reset()
+	w.inline.WriteString(trimmed + "[" + number + "]" + text[len(trimmed):])
+}
+
+// nested renders content with a different prefix for its lines
+func (w *blockWalker) nested(prefix string, render func()) {
+	saved := w.prefix
+	w.prefix = prefix
+	render()
+	w.flush()
+	w.prefix = saved
+}
+
+// list renders the items of a ul or ol with bullets or numbers
+func (w *blockWalker) list(n *html.Node) {
+	number := 1
+	if start, err := strconv.Atoi(htmlAttr(n, "start")); err == nil {
+		number = start
+	}
+	if w.tight == 0 {
+		w.r.println("")
+	}
+	w.tight++
+	defer func() { w.tight-- }()
+
+	indent := w.prefix + "  "
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.Data != "li" {
+			continue
+		}
+		w.listItems++
+		bullet := "• "
+		if n.Data == "ol" {
+			bullet = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		w.marker = indent + bullet
+		w.nested(indent+strings.Repeat(" ", utf8.RuneCountInString(bullet)), func() { w.children(item) })
+		w.marker = ""
+	}
+}
+
+// definitions renders a dl with its terms flush and their descriptions indented
+func (w *blockWalker) definitions(n *html.Node) {
+	if w.tight == 0 {
+		w.r.println("")
+	}
+	w.tight++
+	defer func() { w.tight-- }()
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		// Groups of terms and descriptions may be wrapped in a div
+		items := []*html.Node{child}
+		if child.Type == html.ElementNode && child.Data == "div" {
+			items = nil
+			for item := child.FirstChild; item != nil; item = item.NextSibling {
+				items = append(items, item)
+			}
+		}
+		for _, item := range items {
+			if item.Type != html.ElementNode {
+				continue
+			}
+			switch item.Data {
+			case "dt":
+				w.nested(w.prefix, func() { w.children(item) })
+			case "dd":
+				w.nested(w.prefix+"    ", func() { w.children(item) })
+			}
+		}
+	}
+}
+
+// preformatted writes text keeping its line breaks and spacing
+func (w *blockWalker) preformatted(text string) {
+	text = strings.Trim(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if w.tight == 0 {
+		w.paragraphs++
+		w.r.println("")
+	}
+	for _, line := range strings.Split(text, "\n") {
+		w.r.println(w.prefix + "    " + strings.TrimRight(line, " \t\r"))
+	}
+}
+
+// image renders an image as ASCII art, skipping tracking pixels and SVGs
+func (w *blockWalker) image(n *html.Node) {
+	src := htmlAttr(n, "src")
+	if src == "" || isDecorativeImage(src) {
+		return
+	}
+	w.images++
+	w.r.renderImage(w.ctx, w.images, src, htmlAttr(n, "alt"), w.baseURL)
+}
+
+// wrapText breaks a line into lines of at most width runes at spaces; words
+// longer than the width get a line of their own
+func wrapText(text string, width int) []string {
+	if width < 20 {
+		width = 20
+	}
+	var lines []string
+	var line strings.Builder
+	length := 0
+	for _, word := range strings.Split(text, " ") {
+		if word == "" {
+			continue
+		}
+		wordLength := utf8.RuneCountInString(word)
+		if length > 0 && length+1+wordLength > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			length = 0
+		}
+		if length > 0 {
+			line.WriteString(" ")
+			length++
+		}
+		line.WriteString(word)
+		length += wordLength
+	}
+	if length > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// nodeText returns the text of a node and its descendants
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.Data == "script" || child.Data == "style") {
+			continue
+		}
+		if child.Type == html.ElementNode && child.Data == "br" {
+			text.WriteString("\n")
+			continue
+		}
+		text.WriteString(nodeText(child))
+	}
+	return text.String()
+}
+
+// imageAlt returns the alt text of the first image inside a node
+func imageAlt(n *html.Node) string {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if child.Data == "img" {
+			if alt := collapseText(htmlAttr(child, "alt")); alt != "" {
+				return alt
+			}
+		}
+		if alt := imageAlt(child); alt != "" {
+			return alt
+		}
+	}
+	return ""
+}
+
+// hasElement reports whether a node has a descendant element with the given tag
+func hasElement(n *html.Node, tag string) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.Data == tag || hasElement(child, tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkedText is like nodeText with the numbers of links after their text
+func linkedText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch {
+		case child.Type == html.ElementNode && (child.Data == "script" || child.Data == "style"):
+		case child.Type == html.ElementNode && child.Data == "br":
+			text.WriteString("\n")
+		default:
+			text.WriteString(linkedText(child))
+		}
+	}
+	if number := htmlAttr(n, browser.LinkNumberAttr); n.Type == html.ElementNode && n.Data == "a" && number != "" {
+		return strings.TrimRight(text.String(), " \t\r\n") + "[" + number + "]"
+	}
+	return text.String()
+}
+
+// collapseText joins the words of a text with single spaces
+func collapseText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// resolveURL resolves a reference against the page URL
+func resolveURL(ref, baseURL string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ref
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// htmlAttr returns an attribute of an element node
+func htmlAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
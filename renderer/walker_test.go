@@ -0,0 +1,60 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBlockWalker checks how lists, preformatted text, quotes, definition
+// lists and line breaks are laid out
+func TestBlockWalker(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"nested and numbered lists",
+			`<ul><li>One</li><li>Two<ul><li>Nested</li></ul></li></ul><ol start="3"><li>Three</li><li>Four</li></ol>`, `
+  • One
+  • Two
+      • Nested
+
+  3. Three
+  4. Four
+`},
+		{"preformatted text",
+			`<p>Before</p><pre>  indented
+line   two</pre><p>After</p>`, `
+Before
+
+      indented
+    line   two
+
+After
+`},
+		{"wrapped quote",
+			`<blockquote><p>Quoted text that is long enough to wrap at a narrow width.</p></blockquote>`, `
+│ Quoted text that is long
+│ enough to wrap at a narrow
+│ width.
+`},
+		{"definition list",
+			`<dl><dt>Term</dt><dd>Its definition</dd><dt>Other</dt><dd>Another</dd></dl>`, `
+Term
+    Its definition
+Other
+    Another
+`},
+		{"line breaks and link numbers",
+			`<p>First line<br>second   line<br><a href="/x" data-brauser-link="4">link</a></p>`, `
+First line
+second line
+link[4]
+`},
+	}
+	for _, test := range tests {
+		if got := renderWalked(t, test.fragment, 30); got != strings.TrimPrefix(test.want, "\n") {
+			t.Errorf("%s:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}